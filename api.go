@@ -59,3 +59,29 @@ type Client interface {
 	// ReadDeviceIdentificationSpecificObject reads a specific device identification object.
 	ReadDeviceIdentificationSpecificObject(ctx context.Context, objectID byte) (results map[byte][]byte, err error)
 }
+
+// DeviceIdentifier is implemented by the clients of NewClient and NewClient2.
+// It is not part of Client, so that other implementations of Client need not
+// implement it:
+//
+//	if identifier, ok := client.(modbus.DeviceIdentifier); ok {
+//		identification, err := identifier.IdentifyDevice(ctx)
+//	}
+type DeviceIdentifier interface {
+	// IdentifyDevice reads the identification objects of a remote device up
+	// to the highest access level it supports, falling back to reading the
+	// objects one by one if the device rejects stream access.
+	IdentifyDevice(ctx context.Context) (identification *DeviceIdentification, err error)
+}
+
+// ServerIDReporter is implemented by the clients of NewClient and NewClient2.
+// Like DeviceIdentifier, it is not part of Client:
+//
+//	if reporter, ok := client.(modbus.ServerIDReporter); ok {
+//		serverID, err := reporter.ReportServerID(ctx)
+//	}
+type ServerIDReporter interface {
+	// ReportServerID reads the description of a remote device: the device
+	// specific server id, the run indicator status and additional data.
+	ReportServerID(ctx context.Context) (results []byte, err error)
+}
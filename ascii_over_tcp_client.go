@@ -6,6 +6,7 @@ package modbus
 
 import (
 	"context"
+	"log/slog"
 	"time"
 )
 
@@ -21,7 +22,7 @@ func NewASCIIOverTCPClientHandler(address string) *ASCIIOverTCPClientHandler {
 	handler.Address = address
 	handler.Timeout = tcpTimeout
 	handler.IdleTimeout = tcpIdleTimeout
-	handler.Dial = defaultDialFunc(handler.Timeout)
+	handler.Dial = handler.dial
 	return handler
 }
 
@@ -39,6 +40,7 @@ type asciiTCPTransporter struct {
 func (mb *asciiTCPTransporter) Send(ctx context.Context, aduRequest []byte) (aduResponse []byte, err error) {
 	mb.mu.Lock()
 	defer mb.mu.Unlock()
+	defer func() { err = transportError(err) }()
 
 	// Make sure port is connected
 	if err = mb.connect(ctx); err != nil {
@@ -56,10 +58,22 @@ func (mb *asciiTCPTransporter) Send(ctx context.Context, aduRequest []byte) (adu
 	}
 
 	// Send the request
-	mb.logf("modbus: send %q\n", aduRequest)
+	if logger := mb.logger(); logger.Enabled(ctx, slog.LevelDebug) {
+		logger.Debug("modbus: send", asciiFrameAttrs(LogDirectionSend, aduRequest)...)
+	}
 	if _, err = mb.conn.Write(aduRequest); err != nil {
 		return
 	}
+	mb.observer().RequestSent(len(aduRequest))
+	sent := time.Now()
+	defer func() {
+		if err != nil {
+			mb.logger().Warn("modbus: read error", append(errorAttrs(err, ""), durationAttr(sent))...)
+		}
+		if isTimeout(err) {
+			mb.observer().Timeout()
+		}
+	}()
 	// Get the response
 	var n, length int
 	var data [asciiMaxSize]byte
@@ -79,6 +93,9 @@ func (mb *asciiTCPTransporter) Send(ctx context.Context, aduRequest []byte) (adu
 		}
 	}
 	aduResponse = data[:length]
-	mb.logf("modbus: recv %q\n", aduResponse)
+	mb.observer().ResponseReceived(len(aduResponse), time.Since(sent))
+	if logger := mb.logger(); logger.Enabled(ctx, slog.LevelDebug) {
+		logger.Debug("modbus: recv", append(asciiFrameAttrs(LogDirectionRecv, aduResponse), durationAttr(sent))...)
+	}
 	return
 }
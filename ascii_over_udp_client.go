@@ -0,0 +1,42 @@
+package modbus
+
+import (
+	"encoding/hex"
+	"slices"
+)
+
+// ASCIIOverUDPClientHandler implements Packager and Transporter interface for
+// modbus ASCII frames carried in UDP datagrams.
+type ASCIIOverUDPClientHandler struct {
+	asciiPackager
+	udpTransporter
+}
+
+// NewASCIIOverUDPClientHandler allocates and initializes a ASCIIOverUDPClientHandler.
+func NewASCIIOverUDPClientHandler(address string, options ...UDPClientHandlerOption) *ASCIIOverUDPClientHandler {
+	handler := &ASCIIOverUDPClientHandler{}
+	handler.configure(address, asciiResponseMatches, asciiFrameAttrs, options)
+	return handler
+}
+
+// ASCIIOverUDPClient creates ASCII over UDP client with default handler and given connect string.
+func ASCIIOverUDPClient(address string) Client {
+	handler := NewASCIIOverUDPClientHandler(address)
+	return NewClient(handler)
+}
+
+// asciiResponseMatches reports whether an ASCII datagram is addressed from the
+// slave and with the function of the request. The LRC is left to the packager.
+func asciiResponseMatches(aduRequest, aduResponse []byte) bool {
+	if len(aduRequest) < 5 || len(aduResponse) < 5 || !slices.Contains(asciiStart, string(aduResponse[:1])) {
+		return false
+	}
+	var request, response [2]byte
+	if _, err := hex.Decode(request[:], aduRequest[1:5]); err != nil {
+		return false
+	}
+	if _, err := hex.Decode(response[:], aduResponse[1:5]); err != nil {
+		return false
+	}
+	return response[0] == request[0] && (response[1] == request[1] || response[1] == request[1]|0x80)
+}
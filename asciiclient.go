@@ -10,6 +10,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
+	"log/slog"
 	"time"
 )
 
@@ -56,6 +57,11 @@ func (mb *asciiPackager) SetSlave(slaveID byte) {
 	mb.SlaveID = slaveID
 }
 
+// slave returns the modbus slave id used for the next client operations
+func (mb *asciiPackager) slave() byte {
+	return mb.SlaveID
+}
+
 // Encode encodes PDU in an ASCII frame:
 //
 //	Start           : 1 char
@@ -95,24 +101,24 @@ func (mb *asciiPackager) Verify(aduRequest []byte, aduResponse []byte) (err erro
 	length := len(aduResponse)
 	// Minimum size (including address, function and LRC)
 	if length < asciiMinSize+6 {
-		err = fmt.Errorf("modbus: response length '%v' does not meet minimum '%v'", length, 9)
+		err = errorf(ErrFraming, "modbus: response length '%v' does not meet minimum '%v'", length, 9)
 		return
 	}
 	// Length excluding colon must be an even number
 	if length%2 != 1 {
-		err = fmt.Errorf("modbus: response length '%v' is not an even number", length-1)
+		err = errorf(ErrFraming, "modbus: response length '%v' is not an even number", length-1)
 		return
 	}
 	// First char must be a colon
 	str := string(aduResponse[0:len(asciiStart[0])])
 	if !isStartCharacter(str) {
-		err = fmt.Errorf("modbus: response frame '%v'... is not started with '%v'", str, asciiStart)
+		err = errorf(ErrFraming, "modbus: response frame '%v'... is not started with '%v'", str, asciiStart)
 		return
 	}
 	// 2 last chars must be \r\n
 	str = string(aduResponse[len(aduResponse)-len(asciiEnd):])
 	if str != asciiEnd {
-		err = fmt.Errorf("modbus: response frame ...'%v' is not ended with '%v'", str, asciiEnd)
+		err = errorf(ErrFraming, "modbus: response frame ...'%v' is not ended with '%v'", str, asciiEnd)
 		return
 	}
 	// Slave id
@@ -125,7 +131,7 @@ func (mb *asciiPackager) Verify(aduRequest []byte, aduResponse []byte) (err erro
 		return
 	}
 	if responseVal != requestVal {
-		err = fmt.Errorf("modbus: response slave id '%v' does not match request '%v'", responseVal, requestVal)
+		err = errorf(ErrResponseMismatch, "modbus: response slave id '%v' does not match request '%v'", responseVal, requestVal)
 		return
 	}
 	return
@@ -160,7 +166,7 @@ func (mb *asciiPackager) Decode(adu []byte) (pdu *ProtocolDataUnit, err error) {
 	lrc.reset()
 	lrc.pushByte(address).pushByte(pdu.FunctionCode).pushBytes(pdu.Data)
 	if lrcVal != lrc.value() {
-		err = fmt.Errorf("modbus: response lrc '%v' does not match expected '%v'", lrcVal, lrc.value())
+		err = &ChecksumError{Kind: "lrc", Got: uint16(lrcVal), Expected: uint16(lrc.value())}
 		return
 	}
 	return
@@ -174,6 +180,7 @@ type asciiSerialTransporter struct {
 func (mb *asciiSerialTransporter) Send(ctx context.Context, aduRequest []byte) (aduResponse []byte, err error) {
 	mb.mu.Lock()
 	defer mb.mu.Unlock()
+	defer func() { err = transportError(err) }()
 
 	// Make sure port is connected
 	if err = mb.connect(ctx); err != nil {
@@ -187,7 +194,9 @@ func (mb *asciiSerialTransporter) Send(ctx context.Context, aduRequest []byte) (
 
 	for {
 		// Send the request
-		mb.logf("modbus: send % x\n", aduRequest)
+		if logger := mb.logger(); logger.Enabled(ctx, slog.LevelDebug) {
+			logger.Debug("modbus: send", asciiFrameAttrs(LogDirectionSend, aduRequest)...)
+		}
 		if _, err = mb.port.Write(aduRequest); err != nil {
 			if mb.shouldRecover(err) {
 				if err = mb.reconnect(ctx, err, linkRecoveryDeadline); err != nil {
@@ -198,11 +207,24 @@ func (mb *asciiSerialTransporter) Send(ctx context.Context, aduRequest []byte) (
 
 			return
 		}
+		mb.observer().RequestSent(len(aduRequest))
+		sent := time.Now()
 		// Get the response
 		connDeadline := time.Now().Add(mb.Timeout)
-		aduResponse, err = readASCII(mb.port, connDeadline)
+		if err = mb.setReadDeadline(connDeadline); err != nil {
+			err = fmt.Errorf("modbus: set read deadline: %w", err)
+			return
+		}
+		if mb.EchoSuppression {
+			err = mb.readEcho(aduRequest)
+		}
+		if err == nil {
+			aduResponse, err = readASCII(mb.port, connDeadline)
+		}
 		if aduResponse != nil {
-			mb.logf("modbus: recv % x\n", aduResponse[:])
+			if logger := mb.logger(); logger.Enabled(ctx, slog.LevelDebug) {
+				logger.Debug("modbus: recv", append(asciiFrameAttrs(LogDirectionRecv, aduResponse), durationAttr(sent))...)
+			}
 		}
 		if err != nil {
 			if mb.shouldRecover(err) {
@@ -212,10 +234,13 @@ func (mb *asciiSerialTransporter) Send(ctx context.Context, aduRequest []byte) (
 				continue
 			}
 			// Unknown error
-			mb.logf("modbus: read error: %v", err)
+			mb.logger().Warn("modbus: read error", append(errorAttrs(err, ""), durationAttr(sent))...)
+			if isTimeout(err) {
+				mb.observer().Timeout()
+			}
 			return
 		}
-
+		mb.observer().ResponseReceived(len(aduResponse), time.Since(sent))
 		return
 	}
 }
@@ -0,0 +1,169 @@
+package modbus
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+const (
+	// Default timeout of a single probe request
+	detectTimeout = 200 * time.Millisecond
+	// Default number of probe requests per candidate answering the first one
+	detectAttempts = 3
+)
+
+// SerialDetection is a working serial configuration found by a
+// SerialDetector, with the quality of the responses.
+type SerialDetection struct {
+	BaudRate int
+	Parity   string
+	StopBits int
+	SlaveID  byte
+	// Responses is the number of the Attempts probes answered, including
+	// exception responses.
+	Responses int
+	Attempts  int
+	// Garbled is the number of responses failing the checksum or framing.
+	Garbled int
+	// Latency is the mean time to a response.
+	Latency time.Duration
+}
+
+// SerialDetector finds the line settings and slave ids of RTU devices on a
+// serial port by probing every combination of the candidates with a
+// harmless request. Candidates not answering the first probe are skipped.
+type SerialDetector struct {
+	// Serial port device path
+	Address   string
+	BaudRates []int
+	// Parities are "N", "E" or "O"
+	Parities []string
+	StopBits []int
+	SlaveIDs []byte
+	// Timeout of a single probe request
+	Timeout time.Duration
+	// Attempts is the number of probe requests of answering candidates.
+	Attempts int
+	// Probe sends the probe request. If nil, the basic device
+	// identification is read; devices not supporting it answer with an
+	// exception, which counts as response too.
+	Probe func(ctx context.Context, client Client) error
+	// Open opens the serial port, e.g. to wrap it for tests.
+	// If nil, the port is opened using the serial package.
+	Open SerialOpenFunc
+}
+
+// NewSerialDetector allocates a SerialDetector probing the common baud rates,
+// parities and stop bits of the serial port at address for slave id 1.
+func NewSerialDetector(address string) *SerialDetector {
+	return &SerialDetector{
+		Address:   address,
+		BaudRates: []int{19200, 9600, 38400, 57600, 115200, 4800, 2400},
+		Parities:  []string{"E", "N", "O"},
+		StopBits:  []int{1, 2},
+		SlaveIDs:  []byte{1},
+		Timeout:   detectTimeout,
+		Attempts:  detectAttempts,
+	}
+}
+
+// ProbeRegister returns a probe reading the holding register at address.
+func ProbeRegister(address uint16) func(ctx context.Context, client Client) error {
+	return func(ctx context.Context, client Client) error {
+		_, err := client.ReadHoldingRegisters(ctx, address, 1)
+		return err
+	}
+}
+
+// Detect probes all candidates and returns the answering ones, best first:
+// by most responses, fewest garbled responses and lowest latency. If ctx is
+// done, the candidates found so far are returned with the error of ctx.
+func (d *SerialDetector) Detect(ctx context.Context) ([]SerialDetection, error) {
+	var detections []SerialDetection
+	for _, baudRate := range d.BaudRates {
+		for _, parity := range d.Parities {
+			for _, stopBits := range d.StopBits {
+				found, err := d.detect(ctx, baudRate, parity, stopBits)
+				detections = append(detections, found...)
+				if err != nil {
+					rankDetections(detections)
+					return detections, err
+				}
+			}
+		}
+	}
+	rankDetections(detections)
+	return detections, nil
+}
+
+// detect probes the slave ids with one line setting.
+func (d *SerialDetector) detect(ctx context.Context, baudRate int, parity string, stopBits int) ([]SerialDetection, error) {
+	handler := NewRTUClientHandler(d.Address)
+	handler.BaudRate = baudRate
+	handler.DataBits = 8
+	handler.Parity = parity
+	handler.StopBits = stopBits
+	handler.Timeout = d.Timeout
+	handler.Open = d.Open
+	defer handler.Close()
+	if err := handler.Connect(ctx); err != nil {
+		return nil, err
+	}
+	client := NewClient(handler)
+
+	probe := d.Probe
+	if probe == nil {
+		probe = func(ctx context.Context, client Client) error {
+			_, err := client.ReadDeviceIdentification(ctx, ReadDeviceIDCodeBasic)
+			return err
+		}
+	}
+
+	var detections []SerialDetection
+	for _, slaveID := range d.SlaveIDs {
+		handler.SetSlave(slaveID)
+		detection := SerialDetection{BaudRate: baudRate, Parity: parity, StopBits: stopBits, SlaveID: slaveID}
+		var latency time.Duration
+		for attempt := 0; attempt < max(d.Attempts, 1); attempt++ {
+			if err := ctx.Err(); err != nil {
+				return detections, err
+			}
+			start := time.Now()
+			err := probe(ctx, client)
+			detection.Attempts++
+			switch {
+			case err == nil || IsDeviceException(err):
+				detection.Responses++
+				latency += time.Since(start)
+			case errors.Is(err, ErrChecksum) || errors.Is(err, ErrFraming):
+				detection.Garbled++
+			}
+			if attempt == 0 && detection.Responses+detection.Garbled == 0 {
+				// silent candidate
+				break
+			}
+		}
+		if detection.Responses > 0 {
+			detection.Latency = latency / time.Duration(detection.Responses)
+			detections = append(detections, detection)
+		}
+	}
+	return detections, nil
+}
+
+// rankDetections sorts detections by quality, best first.
+func rankDetections(detections []SerialDetection) {
+	sort.SliceStable(detections, func(i, j int) bool {
+		a, b := detections[i], detections[j]
+		ar, br := a.Responses*b.Attempts, b.Responses*a.Attempts
+		if ar != br {
+			return ar > br
+		}
+		if a.Garbled != b.Garbled {
+			return a.Garbled < b.Garbled
+		}
+		return a.Latency < b.Latency
+	})
+}
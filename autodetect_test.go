@@ -0,0 +1,100 @@
+package modbus
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/grid-x/serial"
+)
+
+// detectableDevice returns a SerialOpenFunc of ports on which slave 5
+// answers device identification requests at 19200 baud, 8N1, with an
+// exception. At 9600 baud it answers with a broken checksum.
+func detectableDevice(t *testing.T) SerialOpenFunc {
+	return func(c *serial.Config) (io.ReadWriteCloser, error) {
+		line, device := net.Pipe()
+		t.Cleanup(func() { device.Close() })
+		go func() {
+			request := make([]byte, 7)
+			for {
+				if _, err := io.ReadFull(device, request); err != nil {
+					return
+				}
+				if request[0] != 5 || c.Parity != "N" || c.StopBits != 1 {
+					continue
+				}
+				response, _ := (&rtuPackager{SlaveID: 5}).Encode(&ProtocolDataUnit{FunctionCode: request[1] | 0x80, Data: []byte{ExceptionCodeIllegalFunction}})
+				switch c.BaudRate {
+				case 9600:
+					response[len(response)-1] ^= 0xFF
+				case 19200:
+				default:
+					continue
+				}
+				device.Write(response)
+			}
+		}()
+		return line, nil
+	}
+}
+
+func TestSerialDetector(t *testing.T) {
+	detector := NewSerialDetector("/dev/ttyUSB0")
+	detector.BaudRates = []int{9600, 19200, 38400}
+	detector.Parities = []string{"E", "N"}
+	detector.StopBits = []int{1}
+	detector.SlaveIDs = []byte{1, 5}
+	detector.Timeout = 20 * time.Millisecond
+	detector.Open = detectableDevice(t)
+
+	detections, err := detector.Detect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(detections) != 1 {
+		t.Fatalf("expected a single detection, got %+v", detections)
+	}
+	d := detections[0]
+	if d.BaudRate != 19200 || d.Parity != "N" || d.StopBits != 1 || d.SlaveID != 5 {
+		t.Fatalf("unexpected detection %+v", d)
+	}
+	if d.Responses != 3 || d.Attempts != 3 {
+		t.Fatalf("expected 3 of 3 responses, got %d of %d", d.Responses, d.Attempts)
+	}
+}
+
+func TestSerialDetectorCancel(t *testing.T) {
+	detector := NewSerialDetector("/dev/ttyUSB0")
+	detector.SlaveIDs = []byte{1, 2, 3, 4, 5}
+	detector.Timeout = 20 * time.Millisecond
+	detector.Open = detectableDevice(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := detector.Detect(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected detection to stop on cancel, took %v", elapsed)
+	}
+}
+
+func TestRankDetections(t *testing.T) {
+	detections := []SerialDetection{
+		{SlaveID: 1, Responses: 2, Attempts: 3},
+		{SlaveID: 2, Responses: 3, Attempts: 3, Garbled: 1},
+		{SlaveID: 3, Responses: 3, Attempts: 3, Latency: 20 * time.Millisecond},
+		{SlaveID: 4, Responses: 3, Attempts: 3, Latency: 10 * time.Millisecond},
+	}
+	rankDetections(detections)
+	for i, slaveID := range []byte{4, 3, 2, 1} {
+		if detections[i].SlaveID != slaveID {
+			t.Fatalf("unexpected ranking %+v", detections)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package modbus
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"sort"
+)
+
+const (
+	// Maximum number of registers of a single Write Multiple Registers request
+	batchMaxRegisters = 123
+	// Maximum number of coils of a single Write Multiple Coils request
+	batchMaxCoils = 1968
+)
+
+// WriteResult is the outcome of a single request of a WriteBatch.
+type WriteResult struct {
+	FunctionCode byte
+	Address      uint16
+	Quantity     uint16
+	Err          error
+}
+
+// bitUpdate sets the bits of a register selected by mask to value.
+type bitUpdate struct {
+	mask, value uint16
+}
+
+// WriteBatch collects coil and holding register writes and executes them
+// with as few requests as possible: contiguous values are merged into Write
+// Multiple Coils and Write Multiple Registers requests within the protocol
+// limits, single values are written with Write Single Coil and Write Single
+// Register.
+//
+// The requests are executed in a deterministic order: coils first, then
+// registers, then bit updates, each by ascending address. A value added
+// twice for the same address is written once with the last value.
+type WriteBatch struct {
+	// MaskWrite makes bit updates use Mask Write Register. Otherwise the
+	// register is read, modified and written back.
+	MaskWrite bool
+
+	client    Client
+	coils     map[uint16]bool
+	registers map[uint16]uint16
+	bits      map[uint16]bitUpdate
+}
+
+// NewWriteBatch allocates an empty WriteBatch writing with client.
+func NewWriteBatch(client Client) *WriteBatch {
+	return &WriteBatch{
+		client:    client,
+		coils:     make(map[uint16]bool),
+		registers: make(map[uint16]uint16),
+		bits:      make(map[uint16]bitUpdate),
+	}
+}
+
+// Coil adds a coil write.
+func (b *WriteBatch) Coil(address uint16, value bool) *WriteBatch {
+	b.coils[address] = value
+	return b
+}
+
+// Register adds a holding register write.
+func (b *WriteBatch) Register(address, value uint16) *WriteBatch {
+	b.registers[address] = value
+	return b
+}
+
+// Registers adds writes of consecutive holding registers starting at address.
+func (b *WriteBatch) Registers(address uint16, values ...uint16) *WriteBatch {
+	for i, value := range values {
+		b.registers[address+uint16(i)] = value
+	}
+	return b
+}
+
+// Bits adds an update of the bits of a holding register selected by mask to
+// the corresponding bits of value, leaving the other bits unchanged. Updates
+// of a register that is also written with Register are applied to the
+// written value.
+func (b *WriteBatch) Bits(address, mask, value uint16) *WriteBatch {
+	update := b.bits[address]
+	update.value = update.value&^mask | value&mask
+	update.mask |= mask
+	b.bits[address] = update
+	return b
+}
+
+// Len returns the number of requests Execute would send, not counting the
+// reads of read-modify-write bit updates.
+func (b *WriteBatch) Len() int {
+	coils, registers, bits := b.plan()
+	return len(coils) + len(registers) + len(bits)
+}
+
+// Execute sends all requests of the batch and returns their results in the
+// order they were sent. All requests are sent even if some fail; the returned
+// error joins the errors of the failed ones.
+func (b *WriteBatch) Execute(ctx context.Context) ([]WriteResult, error) {
+	coils, registers, bits := b.plan()
+	results := make([]WriteResult, 0, len(coils)+len(registers)+len(bits))
+	var errs []error
+	add := func(result WriteResult) {
+		results = append(results, result)
+		if result.Err != nil {
+			errs = append(errs, result.Err)
+		}
+	}
+
+	for _, run := range coils {
+		add(b.writeCoils(ctx, run))
+	}
+	for _, run := range registers {
+		add(b.writeRegisters(ctx, run))
+	}
+	for _, address := range bits {
+		add(b.updateBits(ctx, address, b.bits[address]))
+	}
+	return results, errors.Join(errs...)
+}
+
+// addressRun is a run of contiguous addresses.
+type addressRun struct {
+	address, quantity uint16
+}
+
+// plan returns the runs of coils and registers to write and the addresses of
+// the remaining bit updates.
+func (b *WriteBatch) plan() (coils, registers []addressRun, bits []uint16) {
+	coils = runs(sortedKeys(b.coils), batchMaxCoils)
+	registers = runs(sortedKeys(b.registers), batchMaxRegisters)
+	for _, address := range sortedKeys(b.bits) {
+		if _, ok := b.registers[address]; !ok {
+			bits = append(bits, address)
+		}
+	}
+	return
+}
+
+func (b *WriteBatch) writeCoils(ctx context.Context, run addressRun) WriteResult {
+	if run.quantity == 1 {
+		var value uint16
+		if b.coils[run.address] {
+			value = 0xFF00
+		}
+		_, err := b.client.WriteSingleCoil(ctx, run.address, value)
+		return WriteResult{FunctionCode: FuncCodeWriteSingleCoil, Address: run.address, Quantity: 1, Err: err}
+	}
+	value := make([]byte, (run.quantity+7)/8)
+	for i := uint16(0); i < run.quantity; i++ {
+		if b.coils[run.address+i] {
+			value[i/8] |= 1 << (i % 8)
+		}
+	}
+	_, err := b.client.WriteMultipleCoils(ctx, run.address, run.quantity, value)
+	return WriteResult{FunctionCode: FuncCodeWriteMultipleCoils, Address: run.address, Quantity: run.quantity, Err: err}
+}
+
+func (b *WriteBatch) writeRegisters(ctx context.Context, run addressRun) WriteResult {
+	values := make([]uint16, run.quantity)
+	for i := range values {
+		address := run.address + uint16(i)
+		values[i] = b.registers[address]
+		if update, ok := b.bits[address]; ok {
+			values[i] = values[i]&^update.mask | update.value
+		}
+	}
+	if run.quantity == 1 {
+		_, err := b.client.WriteSingleRegister(ctx, run.address, values[0])
+		return WriteResult{FunctionCode: FuncCodeWriteSingleRegister, Address: run.address, Quantity: 1, Err: err}
+	}
+	_, err := b.client.WriteMultipleRegisters(ctx, run.address, run.quantity, dataBlock(values...))
+	return WriteResult{FunctionCode: FuncCodeWriteMultipleRegisters, Address: run.address, Quantity: run.quantity, Err: err}
+}
+
+func (b *WriteBatch) updateBits(ctx context.Context, address uint16, update bitUpdate) WriteResult {
+	if b.MaskWrite {
+		_, err := b.client.MaskWriteRegister(ctx, address, ^update.mask, update.value)
+		return WriteResult{FunctionCode: FuncCodeMaskWriteRegister, Address: address, Quantity: 1, Err: err}
+	}
+	results, err := b.client.ReadHoldingRegisters(ctx, address, 1)
+	if err != nil {
+		return WriteResult{FunctionCode: FuncCodeReadHoldingRegisters, Address: address, Quantity: 1, Err: err}
+	}
+	if len(results) != 2 {
+		err = &DataSizeError{ExpectedBytes: 2, ActualBytes: len(results)}
+		return WriteResult{FunctionCode: FuncCodeReadHoldingRegisters, Address: address, Quantity: 1, Err: err}
+	}
+	value := binary.BigEndian.Uint16(results)&^update.mask | update.value
+	_, err = b.client.WriteSingleRegister(ctx, address, value)
+	return WriteResult{FunctionCode: FuncCodeWriteSingleRegister, Address: address, Quantity: 1, Err: err}
+}
+
+// runs splits sorted addresses into runs of contiguous addresses of at most
+// max addresses.
+func runs(addresses []uint16, max uint16) []addressRun {
+	var result []addressRun
+	for _, address := range addresses {
+		if n := len(result); n > 0 {
+			last := &result[n-1]
+			if last.quantity < max && uint32(last.address)+uint32(last.quantity) == uint32(address) {
+				last.quantity++
+				continue
+			}
+		}
+		result = append(result, addressRun{address: address, quantity: 1})
+	}
+	return result
+}
+
+func sortedKeys[V any](m map[uint16]V) []uint16 {
+	keys := make([]uint16, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
@@ -0,0 +1,99 @@
+package modbus
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestWriteBatch(t *testing.T) {
+	memory := newMemoryResponder()
+	memory.registers[30] = 0xFF00
+	memory.registers[31] = 0x00FF
+	tr := &pduTransporter{respond: memory.respond}
+	batch := NewWriteBatch(NewClient2(&rtuPackager{SlaveID: 1}, tr))
+
+	batch.Register(12, 2).Register(10, 0).Register(11, 1).Register(20, 7).Register(10, 5)
+	batch.Coil(1, true).Coil(2, false).Coil(3, true)
+	batch.Bits(30, 0x0F00, 0x0500).Bits(31, 0x0003, 0x0001)
+	batch.Bits(20, 0x0100, 0x0100)
+	results, err := batch.Execute(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []WriteResult{
+		{FunctionCode: FuncCodeWriteMultipleCoils, Address: 1, Quantity: 3},
+		{FunctionCode: FuncCodeWriteMultipleRegisters, Address: 10, Quantity: 3},
+		{FunctionCode: FuncCodeWriteSingleRegister, Address: 20, Quantity: 1},
+		{FunctionCode: FuncCodeWriteSingleRegister, Address: 30, Quantity: 1},
+		{FunctionCode: FuncCodeWriteSingleRegister, Address: 31, Quantity: 1},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Fatalf("expected %+v, got %+v", want, results)
+	}
+	registers := map[uint16]uint16{10: 5, 11: 1, 12: 2, 20: 0x0107, 30: 0xF500, 31: 0x00FD}
+	if !reflect.DeepEqual(memory.registers, registers) {
+		t.Fatalf("expected registers %v, got %v", registers, memory.registers)
+	}
+	if coils := map[uint16]bool{1: true, 2: false, 3: true}; !reflect.DeepEqual(memory.coils, coils) {
+		t.Fatalf("expected coils %v, got %v", coils, memory.coils)
+	}
+}
+
+func TestWriteBatchMaskWrite(t *testing.T) {
+	memory := newMemoryResponder()
+	memory.registers[4] = 0x0012
+	tr := &pduTransporter{respond: memory.respond}
+	batch := NewWriteBatch(NewClient2(&rtuPackager{SlaveID: 1}, tr))
+	batch.MaskWrite = true
+
+	results, err := batch.Bits(4, 0x00F0, 0x0070).Execute(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].FunctionCode != FuncCodeMaskWriteRegister || len(tr.requests) != 1 {
+		t.Fatalf("expected a single mask write, got %+v", results)
+	}
+	if got := memory.registers[4]; got != 0x0072 {
+		t.Fatalf("expected register 0x0072, got %#04x", got)
+	}
+}
+
+func TestWriteBatchLimits(t *testing.T) {
+	batch := NewWriteBatch(nil)
+	for i := uint16(0); i < 250; i++ {
+		batch.Register(i, i)
+	}
+	for i := uint16(0); i < 2000; i++ {
+		batch.Coil(0xFFFF-i, true)
+	}
+	coils, registers, _ := batch.plan()
+	wantRegisters := []addressRun{{0, 123}, {123, 123}, {246, 4}}
+	if !reflect.DeepEqual(registers, wantRegisters) {
+		t.Fatalf("expected register runs %v, got %v", wantRegisters, registers)
+	}
+	wantCoils := []addressRun{{0xFFFF - 1999, 1968}, {0xFFFF - 31, 32}}
+	if !reflect.DeepEqual(coils, wantCoils) {
+		t.Fatalf("expected coil runs %v, got %v", wantCoils, coils)
+	}
+}
+
+func TestWriteBatchErrors(t *testing.T) {
+	tr := &pduTransporter{respond: func(request *ProtocolDataUnit) *ProtocolDataUnit {
+		if request.FunctionCode == FuncCodeWriteSingleCoil {
+			return &ProtocolDataUnit{FunctionCode: request.FunctionCode | 0x80, Data: []byte{ExceptionCodeIllegalDataAddress}}
+		}
+		return &ProtocolDataUnit{FunctionCode: request.FunctionCode, Data: request.Data[:4]}
+	}}
+	batch := NewWriteBatch(NewClient2(&rtuPackager{SlaveID: 1}, tr))
+
+	results, err := batch.Coil(9, true).Register(1, 1).Execute(context.Background())
+	if !errors.Is(err, ErrIllegalDataAddress) {
+		t.Fatalf("expected illegal data address, got %v", err)
+	}
+	if len(results) != 2 || results[0].Err == nil || results[1].Err != nil {
+		t.Fatalf("expected the failed coil and the written register, got %+v", results)
+	}
+}
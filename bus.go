@@ -0,0 +1,240 @@
+package modbus
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SerialBus shares one serial handler between the clients of several
+// slaves, e.g. goroutines polling different devices on one RS485 port.
+// Requests are sent one at a time: waiting requests of higher priority go
+// first, and among equal priorities the slave served least recently goes
+// first, so that no slave starves. Between a response and a request to a
+// different slave the bus stays silent for TurnaroundDelay.
+type SerialBus struct {
+	// TurnaroundDelay is the silence between a response and a request to a
+	// different slave. Defaults to t3.5 of the baud rate of the handler.
+	TurnaroundDelay time.Duration
+
+	handler ClientHandler
+	// encodeMu serializes the slave id and encoding of handler
+	encodeMu sync.Mutex
+
+	mu      sync.Mutex
+	busy    bool
+	waiters []*busWaiter
+	seq     uint64
+	// served is the time of the last request sent to each slave
+	served    map[byte]time.Time
+	lastSlave byte
+	lastDone  time.Time
+	now       func() time.Time
+}
+
+// busWaiter is a request waiting for the bus.
+type busWaiter struct {
+	slaveID  byte
+	priority int
+	seq      uint64
+	ready    chan struct{}
+}
+
+// NewSerialBus allocates a SerialBus sending requests with handler, which
+// must not be used directly anymore. Clients frame their requests with the
+// packager of handler.
+func NewSerialBus(handler ClientHandler) *SerialBus {
+	bus := &SerialBus{
+		handler: handler,
+		served:  make(map[byte]time.Time),
+		now:     time.Now,
+	}
+	var baudRate int
+	switch h := handler.(type) {
+	case *RTUClientHandler:
+		baudRate = h.BaudRate
+	case *ASCIIClientHandler:
+		baudRate = h.BaudRate
+	}
+	_, bus.TurnaroundDelay = rtuSilentIntervals(baudRate)
+	return bus
+}
+
+// Client returns a client of the given slave on the bus.
+func (b *SerialBus) Client(slaveID byte, options ...ClientOption) Client {
+	return b.PriorityClient(slaveID, 0, options...)
+}
+
+// PriorityClient returns a client of the given slave on the bus, whose
+// requests go before the waiting requests of clients of lower priority.
+func (b *SerialBus) PriorityClient(slaveID byte, priority int, options ...ClientOption) Client {
+	packager := &busPackager{bus: b, slaveID: slaveID}
+	transporter := &busTransporter{bus: b, slaveID: slaveID, priority: priority}
+	return NewClient2(packager, transporter, options...)
+}
+
+// Connect connects the handler of the bus.
+func (b *SerialBus) Connect(ctx context.Context) error {
+	return b.handler.Connect(ctx)
+}
+
+// Close closes the handler of the bus.
+func (b *SerialBus) Close() error {
+	return b.handler.Close()
+}
+
+// acquire waits until the bus is granted to a request to slaveID.
+func (b *SerialBus) acquire(ctx context.Context, slaveID byte, priority int) error {
+	b.mu.Lock()
+	if !b.busy {
+		b.busy = true
+		b.mu.Unlock()
+		return nil
+	}
+	b.seq++
+	w := &busWaiter{slaveID: slaveID, priority: priority, seq: b.seq, ready: make(chan struct{})}
+	b.waiters = append(b.waiters, w)
+	b.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		for i, waiter := range b.waiters {
+			if waiter == w {
+				b.waiters = append(b.waiters[:i], b.waiters[i+1:]...)
+				return ctx.Err()
+			}
+		}
+		// Granted meanwhile, pass the bus on
+		b.handOver()
+		return ctx.Err()
+	}
+}
+
+// release passes the bus on to the next waiting request.
+func (b *SerialBus) release(slaveID byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastSlave = slaveID
+	b.lastDone = b.now()
+	b.handOver()
+}
+
+// handOver grants the bus to the waiting request of the highest priority,
+// preferring the slave served least recently. Caller must hold the mutex.
+func (b *SerialBus) handOver() {
+	if len(b.waiters) == 0 {
+		b.busy = false
+		return
+	}
+	next := 0
+	for i, w := range b.waiters[1:] {
+		if b.before(w, b.waiters[next]) {
+			next = i + 1
+		}
+	}
+	w := b.waiters[next]
+	b.waiters = append(b.waiters[:next], b.waiters[next+1:]...)
+	close(w.ready)
+}
+
+// before reports whether w goes before v. Caller must hold the mutex.
+func (b *SerialBus) before(w, v *busWaiter) bool {
+	if w.priority != v.priority {
+		return w.priority > v.priority
+	}
+	if ws, vs := b.served[w.slaveID], b.served[v.slaveID]; !ws.Equal(vs) {
+		return ws.Before(vs)
+	}
+	return w.seq < v.seq
+}
+
+// turnaround waits for the silence due before a request to slaveID.
+func (b *SerialBus) turnaround(ctx context.Context, slaveID byte) error {
+	b.mu.Lock()
+	b.served[slaveID] = b.now()
+	var wait time.Duration
+	if !b.lastDone.IsZero() && b.lastSlave != slaveID {
+		wait = b.TurnaroundDelay - b.now().Sub(b.lastDone)
+	}
+	b.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// busPackager frames the requests of a slave with the handler of a
+// SerialBus.
+type busPackager struct {
+	bus     *SerialBus
+	slaveID byte
+}
+
+// SetSlave is a no-op, the slave of a bus client is fixed.
+func (p *busPackager) SetSlave(byte) {}
+
+func (p *busPackager) Encode(pdu *ProtocolDataUnit) (adu []byte, err error) {
+	p.bus.encodeMu.Lock()
+	defer p.bus.encodeMu.Unlock()
+	p.bus.handler.SetSlave(p.slaveID)
+	return p.bus.handler.Encode(pdu)
+}
+
+func (p *busPackager) Decode(adu []byte) (pdu *ProtocolDataUnit, err error) {
+	return p.bus.handler.Decode(adu)
+}
+
+func (p *busPackager) Verify(aduRequest []byte, aduResponse []byte) (err error) {
+	return p.bus.handler.Verify(aduRequest, aduResponse)
+}
+
+func (p *busPackager) slave() byte {
+	return p.slaveID
+}
+
+// busTransporter sends the requests of a slave on a SerialBus.
+type busTransporter struct {
+	bus      *SerialBus
+	slaveID  byte
+	priority int
+}
+
+// Send waits for the bus and the turnaround delay, then sends the request.
+func (t *busTransporter) Send(ctx context.Context, aduRequest []byte) (aduResponse []byte, err error) {
+	if err = t.bus.acquire(ctx, t.slaveID, t.priority); err != nil {
+		return
+	}
+	defer t.bus.release(t.slaveID)
+
+	if err = t.bus.turnaround(ctx, t.slaveID); err != nil {
+		return
+	}
+	return t.bus.handler.Send(ctx, aduRequest)
+}
+
+func (t *busTransporter) transportAddress() string {
+	if a, ok := t.bus.handler.(transportAddresser); ok {
+		return a.transportAddress()
+	}
+	return ""
+}
+
+func (t *busTransporter) observer() Observer {
+	if o, ok := t.bus.handler.(observed); ok {
+		return o.observer()
+	}
+	return nopObserver{}
+}
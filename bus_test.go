@@ -0,0 +1,245 @@
+package modbus
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// busHandler answers read holding register requests, recording the order and
+// concurrency of the requests. Requests of slave block wait for unblock.
+type busHandler struct {
+	rtuPackager
+
+	mu        sync.Mutex
+	slaves    []byte
+	sent      []time.Time
+	active    int
+	maxActive int
+	block     byte
+	unblock   chan struct{}
+}
+
+func (h *busHandler) Send(ctx context.Context, aduRequest []byte) ([]byte, error) {
+	h.mu.Lock()
+	h.slaves = append(h.slaves, aduRequest[0])
+	h.sent = append(h.sent, time.Now())
+	h.active++
+	h.maxActive = max(h.maxActive, h.active)
+	h.mu.Unlock()
+
+	if h.unblock != nil && aduRequest[0] == h.block {
+		<-h.unblock
+	}
+	time.Sleep(time.Millisecond)
+
+	h.mu.Lock()
+	h.active--
+	h.mu.Unlock()
+	return (&rtuPackager{SlaveID: aduRequest[0]}).Encode(&ProtocolDataUnit{FunctionCode: aduRequest[1], Data: []byte{2, 0, aduRequest[0]}})
+}
+
+// requests returns the slave ids of the requests sent so far.
+func (h *busHandler) requests() []byte {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]byte(nil), h.slaves...)
+}
+
+// waitForSent waits until n requests were sent.
+func (h *busHandler) waitForSent(t *testing.T, n int) {
+	t.Helper()
+	for i := 0; i < 1000; i++ {
+		if len(h.requests()) >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("expected %d requests", n)
+}
+
+func (h *busHandler) Connect(context.Context) error { return nil }
+func (h *busHandler) Close() error                  { return nil }
+
+// waitForWaiters waits until n requests wait for the bus.
+func waitForWaiters(t *testing.T, bus *SerialBus, n int) {
+	t.Helper()
+	for i := 0; i < 1000; i++ {
+		bus.mu.Lock()
+		waiting := len(bus.waiters)
+		bus.mu.Unlock()
+		if waiting == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("expected %d waiting requests", n)
+}
+
+func TestSerialBusClients(t *testing.T) {
+	handler := &busHandler{}
+	bus := NewSerialBus(handler)
+	bus.TurnaroundDelay = 0
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for slaveID := byte(1); slaveID <= 10; slaveID++ {
+		client := bus.Client(slaveID)
+		wg.Add(1)
+		go func(slaveID byte) {
+			defer wg.Done()
+			for i := 0; i < 5; i++ {
+				results, err := client.ReadHoldingRegisters(ctx, 0, 1)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if results[1] != slaveID {
+					t.Errorf("slave %d: got response of slave %d", slaveID, results[1])
+				}
+			}
+		}(slaveID)
+	}
+	wg.Wait()
+
+	if handler.maxActive != 1 {
+		t.Fatalf("expected requests one at a time, got %d concurrent", handler.maxActive)
+	}
+	if len(handler.slaves) != 50 {
+		t.Fatalf("expected 50 requests, got %d", len(handler.slaves))
+	}
+}
+
+func TestSerialBusQueuing(t *testing.T) {
+	handler := &busHandler{block: 9, unblock: make(chan struct{})}
+	bus := NewSerialBus(handler)
+	bus.TurnaroundDelay = 0
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	read := func(client Client) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.ReadHoldingRegisters(ctx, 0, 1); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	// slave 9 holds the bus until unblocked
+	read(bus.Client(9))
+	handler.waitForSent(t, 1)
+	read(bus.Client(1))
+	waitForWaiters(t, bus, 1)
+	read(bus.Client(1))
+	waitForWaiters(t, bus, 2)
+	read(bus.Client(2))
+	waitForWaiters(t, bus, 3)
+	read(bus.PriorityClient(3, 1))
+	waitForWaiters(t, bus, 4)
+
+	close(handler.unblock)
+	wg.Wait()
+
+	// priority first, then the slave served least recently
+	want := []byte{9, 3, 1, 2, 1}
+	for i, slaveID := range want {
+		if handler.slaves[i] != slaveID {
+			t.Fatalf("expected order %v, got %v", want, handler.slaves)
+		}
+	}
+}
+
+func TestSerialBusTurnaroundDelay(t *testing.T) {
+	handler := &busHandler{}
+	bus := NewSerialBus(handler)
+	bus.TurnaroundDelay = 30 * time.Millisecond
+	ctx := context.Background()
+
+	for _, slaveID := range []byte{1, 1, 2} {
+		if _, err := bus.Client(slaveID).ReadHoldingRegisters(ctx, 0, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if gap := handler.sent[1].Sub(handler.sent[0]); gap >= 30*time.Millisecond {
+		t.Fatalf("expected no delay between requests to the same slave, got %v", gap)
+	}
+	if gap := handler.sent[2].Sub(handler.sent[1]); gap < 30*time.Millisecond {
+		t.Fatalf("expected turnaround delay between slaves, got %v", gap)
+	}
+}
+
+func TestSerialBusContextCancel(t *testing.T) {
+	handler := &busHandler{block: 1, unblock: make(chan struct{})}
+	bus := NewSerialBus(handler)
+	ctx := context.Background()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		bus.Client(1).ReadHoldingRegisters(ctx, 0, 1)
+	}()
+	handler.waitForSent(t, 1)
+
+	waitCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := bus.Client(2).ReadHoldingRegisters(waitCtx, 0, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	waitForWaiters(t, bus, 0)
+
+	close(handler.unblock)
+	<-done
+	if _, err := bus.Client(2).ReadHoldingRegisters(ctx, 0, 1); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// framingHandler records the requests framed by its packager without
+// answering them.
+type framingHandler struct {
+	Packager
+	sent [][]byte
+}
+
+func (h *framingHandler) Send(_ context.Context, aduRequest []byte) ([]byte, error) {
+	h.sent = append(h.sent, aduRequest)
+	return nil, &timeoutError{}
+}
+
+func (h *framingHandler) Connect(context.Context) error { return nil }
+func (h *framingHandler) Close() error                  { return nil }
+
+func TestSerialBusHandlerFraming(t *testing.T) {
+	request := &ProtocolDataUnit{FunctionCode: FuncCodeReadHoldingRegisters, Data: []byte{0, 0, 0, 1}}
+	tests := []struct {
+		name     string
+		packager Packager
+		expected Packager
+	}{
+		{"RTU", &rtuPackager{}, &rtuPackager{SlaveID: 7}},
+		{"ASCII", &asciiPackager{}, &asciiPackager{SlaveID: 7}},
+		{"TCP", &tcpPackager{}, &tcpPackager{SlaveID: 7}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := &framingHandler{Packager: tc.packager}
+			bus := NewSerialBus(handler)
+			bus.TurnaroundDelay = 0
+
+			if _, err := bus.Client(7).ReadHoldingRegisters(context.Background(), 0, 1); !isTimeout(err) {
+				t.Fatalf("expected timeout, got %v", err)
+			}
+			expected, err := tc.expected.Encode(request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(handler.sent) != 1 || !bytes.Equal(handler.sent[0], expected) {
+				t.Errorf("expected request [% x], got %x", expected, handler.sent)
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package modbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// Default number of consecutive failures after which a circuit opens
+	circuitFailureThreshold = 5
+	// Default duration a circuit stays open before a probe is let through
+	circuitOpenTimeout = 30 * time.Second
+)
+
+// CircuitState is the state of the circuit of a single slave.
+type CircuitState int
+
+const (
+	// CircuitClosed lets all requests through.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails all requests fast without touching the transport.
+	CircuitOpen
+	// CircuitHalfOpen lets a single probe request through to find out
+	// whether the slave has recovered.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(s))
+	}
+}
+
+// CircuitOpenError is returned instead of sending a request while the circuit
+// of the addressed slave is open.
+type CircuitOpenError struct {
+	SlaveID byte
+	// RetryAt is the time at which the next probe request is let through.
+	RetryAt time.Time
+}
+
+// Error implements the error interface
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("modbus: circuit open for slave id '%v', retry at %v", e.SlaveID, e.RetryAt.Format(time.RFC3339Nano))
+}
+
+// CircuitBreakerHandler wraps a ClientHandler with one circuit breaker per
+// slave id. A circuit opens after FailureThreshold consecutive transport
+// failures of a slave and fails all further requests to that slave fast with
+// a *CircuitOpenError. After OpenTimeout a single probe request is let
+// through (half-open): if it succeeds the circuit closes, otherwise it opens
+// again. Responses failing verification or decoding by the wrapped packager,
+// e.g. with a checksum or framing error, count as failures as well. Modbus
+// exception responses are answers of a living device and do not count as
+// failures.
+//
+// The slave id is taken from the wrapped packager, so it can be changed with
+// SetSlave on either the CircuitBreakerHandler or the wrapped handler.
+type CircuitBreakerHandler struct {
+	ClientHandler
+
+	// FailureThreshold is the number of consecutive failures after which the
+	// circuit of a slave opens.
+	FailureThreshold int
+	// OpenTimeout is the duration a circuit stays open before a probe request
+	// is let through.
+	OpenTimeout time.Duration
+	// OnStateChange is called whenever the circuit of a slave changes its state.
+	// It is called synchronously from Send and must not block.
+	OnStateChange func(slaveID byte, from, to CircuitState)
+
+	mu       sync.Mutex
+	slaveID  byte
+	circuits map[byte]*circuit
+	now      func() time.Time
+}
+
+// circuit holds the breaker state of a single slave.
+type circuit struct {
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// NewCircuitBreakerHandler allocates and initializes a CircuitBreakerHandler
+// wrapping the given handler.
+func NewCircuitBreakerHandler(handler ClientHandler) *CircuitBreakerHandler {
+	return &CircuitBreakerHandler{
+		ClientHandler:    handler,
+		FailureThreshold: circuitFailureThreshold,
+		OpenTimeout:      circuitOpenTimeout,
+		circuits:         make(map[byte]*circuit),
+		now:              time.Now,
+	}
+}
+
+// SetSlave sets modbus slave id for the next client operations
+func (cb *CircuitBreakerHandler) SetSlave(slaveID byte) {
+	cb.mu.Lock()
+	cb.slaveID = slaveID
+	cb.mu.Unlock()
+	cb.ClientHandler.SetSlave(slaveID)
+}
+
+// State returns the current circuit state of the given slave.
+func (cb *CircuitBreakerHandler) State(slaveID byte) CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if c, ok := cb.circuits[slaveID]; ok {
+		if c.state == CircuitOpen && !cb.now().Before(c.openedAt.Add(cb.OpenTimeout)) {
+			return CircuitHalfOpen
+		}
+		return c.state
+	}
+	return CircuitClosed
+}
+
+// Reset closes the circuit of the given slave and clears its failure count.
+func (cb *CircuitBreakerHandler) Reset(slaveID byte) {
+	cb.mu.Lock()
+	c, ok := cb.circuits[slaveID]
+	var from CircuitState
+	if ok {
+		from = c.state
+		delete(cb.circuits, slaveID)
+	}
+	cb.mu.Unlock()
+
+	if ok && from != CircuitClosed {
+		cb.notify(slaveID, from, CircuitClosed)
+	}
+}
+
+// Send sends the request unless the circuit of the addressed slave is open.
+func (cb *CircuitBreakerHandler) Send(ctx context.Context, aduRequest []byte) (aduResponse []byte, err error) {
+	slaveID := cb.slave()
+	if err = cb.allow(slaveID); err != nil {
+		return
+	}
+	aduResponse, err = cb.ClientHandler.Send(ctx, aduRequest)
+	if err != nil {
+		cb.record(slaveID, err)
+		return
+	}
+	// the client verifies and decodes the response again to report errors
+	verifyErr := cb.ClientHandler.Verify(aduRequest, aduResponse)
+	if verifyErr == nil {
+		_, verifyErr = cb.ClientHandler.Decode(aduResponse)
+	}
+	cb.record(slaveID, verifyErr)
+	return
+}
+
+// slave returns the slave id of the next request.
+func (cb *CircuitBreakerHandler) slave() byte {
+	if s, ok := cb.ClientHandler.(slaveReporter); ok {
+		return s.slave()
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.slaveID
+}
+
+// observer returns the Observer of the wrapped handler if it has one.
+func (cb *CircuitBreakerHandler) observer() Observer {
+	if o, ok := cb.ClientHandler.(observed); ok {
+		return o.observer()
+	}
+	return nopObserver{}
+}
+
+// allow checks whether a request to the given slave may be sent.
+func (cb *CircuitBreakerHandler) allow(slaveID byte) error {
+	cb.mu.Lock()
+	c, ok := cb.circuits[slaveID]
+	if !ok {
+		cb.mu.Unlock()
+		return nil
+	}
+	switch c.state {
+	case CircuitOpen:
+		retryAt := c.openedAt.Add(cb.OpenTimeout)
+		if cb.now().Before(retryAt) {
+			cb.mu.Unlock()
+			return &CircuitOpenError{SlaveID: slaveID, RetryAt: retryAt}
+		}
+		c.state = CircuitHalfOpen
+		c.probing = true
+		cb.mu.Unlock()
+		cb.notify(slaveID, CircuitOpen, CircuitHalfOpen)
+		return nil
+	case CircuitHalfOpen:
+		if c.probing {
+			cb.mu.Unlock()
+			return &CircuitOpenError{SlaveID: slaveID, RetryAt: cb.now()}
+		}
+		c.probing = true
+	}
+	cb.mu.Unlock()
+	return nil
+}
+
+// record updates the circuit of the given slave with the result of a request.
+func (cb *CircuitBreakerHandler) record(slaveID byte, err error) {
+	cb.mu.Lock()
+	c, ok := cb.circuits[slaveID]
+	if !ok {
+		if err == nil || errors.Is(err, context.Canceled) {
+			cb.mu.Unlock()
+			return
+		}
+		c = &circuit{}
+		cb.circuits[slaveID] = c
+	}
+	from := c.state
+	switch {
+	case errors.Is(err, context.Canceled):
+		// the caller gave up, nothing is known about the slave
+		c.probing = false
+	case err == nil:
+		c.state = CircuitClosed
+		c.failures = 0
+		c.probing = false
+	default:
+		c.failures++
+		c.probing = false
+		if c.state == CircuitHalfOpen || c.failures >= cb.FailureThreshold {
+			c.state = CircuitOpen
+			c.openedAt = cb.now()
+		}
+	}
+	to := c.state
+	if to == CircuitClosed && c.failures == 0 {
+		delete(cb.circuits, slaveID)
+	}
+	cb.mu.Unlock()
+
+	if from != to {
+		cb.notify(slaveID, from, to)
+	}
+}
+
+func (cb *CircuitBreakerHandler) notify(slaveID byte, from, to CircuitState) {
+	if cb.OnStateChange != nil {
+		cb.OnStateChange(slaveID, from, to)
+	}
+}
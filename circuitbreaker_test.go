@@ -0,0 +1,202 @@
+package modbus
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// scriptedHandler is a ClientHandler that answers every Send with the next
+// scripted error (nil meaning success) and records the slave ids it was
+// called for.
+type scriptedHandler struct {
+	rtuPackager
+
+	errs  []error
+	sends []byte
+}
+
+func (h *scriptedHandler) Send(_ context.Context, aduRequest []byte) ([]byte, error) {
+	h.sends = append(h.sends, h.SlaveID)
+	var err error
+	if len(h.errs) > 0 {
+		err = h.errs[0]
+		h.errs = h.errs[1:]
+	}
+	if err != nil {
+		return nil, err
+	}
+	return aduRequest, nil
+}
+
+func (h *scriptedHandler) Connect(context.Context) error { return nil }
+func (h *scriptedHandler) Close() error                  { return nil }
+
+// circuitRequest returns an RTU read holding registers request to slaveID,
+// which scriptedHandler echoes as a valid response.
+func circuitRequest(t *testing.T, slaveID byte) []byte {
+	t.Helper()
+	adu, err := (&rtuPackager{SlaveID: slaveID}).Encode(&ProtocolDataUnit{FunctionCode: FuncCodeReadHoldingRegisters, Data: dataBlock(0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return adu
+}
+
+type stateChange struct {
+	slaveID  byte
+	from, to CircuitState
+}
+
+func TestCircuitBreakerOpensAndRecovers(t *testing.T) {
+	inner := &scriptedHandler{errs: []error{io.EOF, io.EOF, io.EOF, nil}}
+	now := time.Now()
+	var changes []stateChange
+
+	cb := NewCircuitBreakerHandler(inner)
+	cb.FailureThreshold = 2
+	cb.OpenTimeout = time.Second
+	cb.OnStateChange = func(slaveID byte, from, to CircuitState) {
+		changes = append(changes, stateChange{slaveID, from, to})
+	}
+	cb.now = func() time.Time { return now }
+	cb.SetSlave(7)
+
+	ctx := context.Background()
+	req := circuitRequest(t, 7)
+	for i := 0; i < 2; i++ {
+		if _, err := cb.Send(ctx, req); !errors.Is(err, io.EOF) {
+			t.Fatalf("send %d: expected EOF, got %v", i, err)
+		}
+	}
+	if got := cb.State(7); got != CircuitOpen {
+		t.Fatalf("expected open circuit, got %v", got)
+	}
+
+	// fails fast while open
+	var openErr *CircuitOpenError
+	if _, err := cb.Send(ctx, req); !errors.As(err, &openErr) || openErr.SlaveID != 7 {
+		t.Fatalf("expected circuit open error, got %v", err)
+	}
+	if len(inner.sends) != 2 {
+		t.Fatalf("expected open circuit not to send, got %d sends", len(inner.sends))
+	}
+
+	// failing probe opens the circuit again
+	now = now.Add(time.Second)
+	if _, err := cb.Send(ctx, req); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected probe to fail with EOF, got %v", err)
+	}
+	if got := cb.State(7); got != CircuitOpen {
+		t.Fatalf("expected open circuit after failed probe, got %v", got)
+	}
+
+	// successful probe closes the circuit
+	now = now.Add(time.Second)
+	if _, err := cb.Send(ctx, req); err != nil {
+		t.Fatalf("expected probe to succeed, got %v", err)
+	}
+	if got := cb.State(7); got != CircuitClosed {
+		t.Fatalf("expected closed circuit, got %v", got)
+	}
+
+	want := []stateChange{
+		{7, CircuitClosed, CircuitOpen},
+		{7, CircuitOpen, CircuitHalfOpen},
+		{7, CircuitHalfOpen, CircuitOpen},
+		{7, CircuitOpen, CircuitHalfOpen},
+		{7, CircuitHalfOpen, CircuitClosed},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("expected state changes %v, got %v", want, changes)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Fatalf("expected state changes %v, got %v", want, changes)
+		}
+	}
+}
+
+func TestCircuitBreakerIsPerSlave(t *testing.T) {
+	inner := &scriptedHandler{errs: []error{io.EOF}}
+	cb := NewCircuitBreakerHandler(inner)
+	cb.FailureThreshold = 1
+
+	ctx := context.Background()
+	// setting the slave id on the wrapped handler is honored as well
+	inner.SlaveID = 1
+	if _, err := cb.Send(ctx, circuitRequest(t, 1)); err == nil {
+		t.Fatal("expected first send to fail")
+	}
+	if got := cb.State(1); got != CircuitOpen {
+		t.Fatalf("expected open circuit for slave 1, got %v", got)
+	}
+
+	cb.SetSlave(2)
+	if _, err := cb.Send(ctx, circuitRequest(t, 2)); err != nil {
+		t.Fatalf("expected healthy slave to be unaffected, got %v", err)
+	}
+
+	cb.Reset(1)
+	cb.SetSlave(1)
+	if _, err := cb.Send(ctx, circuitRequest(t, 1)); err != nil {
+		t.Fatalf("expected reset circuit to let requests through, got %v", err)
+	}
+}
+
+func TestCircuitBreakerIgnoresCancellation(t *testing.T) {
+	inner := &scriptedHandler{errs: []error{context.Canceled, context.Canceled}}
+	cb := NewCircuitBreakerHandler(inner)
+	cb.FailureThreshold = 1
+	cb.SetSlave(1)
+
+	client := NewClient(cb)
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if _, err := client.ReadHoldingRegisters(ctx, 0, 1); !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected cancellation, got %v", err)
+		}
+	}
+	if got := cb.State(1); got != CircuitClosed {
+		t.Fatalf("expected cancellation not to open the circuit, got %v", got)
+	}
+}
+
+// garbledHandler answers every request with a broken checksum.
+type garbledHandler struct {
+	scriptedHandler
+}
+
+func (h *garbledHandler) Send(ctx context.Context, aduRequest []byte) ([]byte, error) {
+	aduResponse, err := h.scriptedHandler.Send(ctx, aduRequest)
+	if err != nil {
+		return nil, err
+	}
+	aduResponse = append([]byte(nil), aduResponse...)
+	aduResponse[len(aduResponse)-1] ^= 0xFF
+	return aduResponse, nil
+}
+
+func TestCircuitBreakerCountsGarbledResponses(t *testing.T) {
+	inner := &garbledHandler{}
+	cb := NewCircuitBreakerHandler(inner)
+	cb.FailureThreshold = 2
+	client := NewClient(cb)
+	cb.SetSlave(3)
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if _, err := client.ReadHoldingRegisters(ctx, 0, 1); !errors.Is(err, ErrChecksum) {
+			t.Fatalf("read %d: expected checksum error, got %v", i, err)
+		}
+	}
+	if got := cb.State(3); got != CircuitOpen {
+		t.Fatalf("expected open circuit, got %v", got)
+	}
+	var openErr *CircuitOpenError
+	if _, err := client.ReadHoldingRegisters(ctx, 0, 1); !errors.As(err, &openErr) {
+		t.Fatalf("expected circuit open error, got %v", err)
+	}
+}
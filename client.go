@@ -7,7 +7,9 @@ package modbus
 import (
 	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"time"
 )
 
 // Logger is the interface to the required logging functions
@@ -26,6 +28,11 @@ func (e *DataSizeError) Error() string {
 	return fmt.Sprintf("modbus: response data size '%d' does not match count '%d'", e.ActualBytes, e.ExpectedBytes)
 }
 
+// Is reports whether target is ErrFraming.
+func (e *DataSizeError) Is(target error) bool {
+	return target == ErrFraming
+}
+
 // ClientHandler is the interface that groups the Packager and Transporter methods.
 type ClientHandler interface {
 	Packager
@@ -33,19 +40,33 @@ type ClientHandler interface {
 	Connector
 }
 
+// slaveReporter is implemented by the packagers of this package and reports
+// the slave id that is used for the next client operations.
+type slaveReporter interface {
+	slave() byte
+}
+
 type client struct {
-	packager    Packager
-	transporter Transporter
+	packager     Packager
+	transporter  Transporter
+	interceptors []Interceptor
 }
 
+// ClientOption configures a client created by NewClient or NewClient2.
+type ClientOption func(*client)
+
 // NewClient creates a new modbus client with given backend handler.
-func NewClient(handler ClientHandler) Client {
-	return &client{packager: handler, transporter: handler}
+func NewClient(handler ClientHandler, options ...ClientOption) Client {
+	return NewClient2(handler, handler, options...)
 }
 
 // NewClient2 creates a new modbus client with given backend packager and transporter.
-func NewClient2(packager Packager, transporter Transporter) Client {
-	return &client{packager: packager, transporter: transporter}
+func NewClient2(packager Packager, transporter Transporter, options ...ClientOption) Client {
+	mb := &client{packager: packager, transporter: transporter}
+	for _, o := range options {
+		o(mb)
+	}
+	return mb
 }
 
 // Request:
@@ -153,7 +174,7 @@ func (mb *client) ReadHoldingRegisters(ctx context.Context, address, quantity ui
 		}
 	}
 	if count != 2*int(quantity) {
-		err = fmt.Errorf("modbus: response data size '%v' does not match request quantity '%v'", length, quantity)
+		err = errorf(ErrResponseMismatch, "modbus: response data size '%v' does not match request quantity '%v'", length, quantity)
 		return
 	}
 	results = response.Data[1 : count+1]
@@ -193,7 +214,7 @@ func (mb *client) ReadInputRegisters(ctx context.Context, address, quantity uint
 		}
 	}
 	if count != 2*int(quantity) {
-		err = fmt.Errorf("modbus: response data size '%v' does not match request quantity '%v'", length, quantity)
+		err = errorf(ErrResponseMismatch, "modbus: response data size '%v' does not match request quantity '%v'", length, quantity)
 		return
 	}
 	results = response.Data[1 : count+1]
@@ -232,13 +253,13 @@ func (mb *client) WriteSingleCoil(ctx context.Context, address, value uint16) (r
 	}
 	respValue := binary.BigEndian.Uint16(response.Data)
 	if address != respValue {
-		err = fmt.Errorf("modbus: response address '%v' does not match request '%v'", respValue, address)
+		err = errorf(ErrResponseMismatch, "modbus: response address '%v' does not match request '%v'", respValue, address)
 		return
 	}
 	results = response.Data[2:]
 	respValue = binary.BigEndian.Uint16(results)
 	if value != respValue {
-		err = fmt.Errorf("modbus: response value '%v' does not match request '%v'", respValue, value)
+		err = errorf(ErrResponseMismatch, "modbus: response value '%v' does not match request '%v'", respValue, value)
 		return
 	}
 	return
@@ -271,13 +292,13 @@ func (mb *client) WriteSingleRegister(ctx context.Context, address, value uint16
 	}
 	respValue := binary.BigEndian.Uint16(response.Data)
 	if address != respValue {
-		err = fmt.Errorf("modbus: response address '%v' does not match request '%v'", respValue, address)
+		err = errorf(ErrResponseMismatch, "modbus: response address '%v' does not match request '%v'", respValue, address)
 		return
 	}
 	results = response.Data[2:]
 	respValue = binary.BigEndian.Uint16(results)
 	if value != respValue {
-		err = fmt.Errorf("modbus: response value '%v' does not match request '%v'", respValue, value)
+		err = errorf(ErrResponseMismatch, "modbus: response value '%v' does not match request '%v'", respValue, value)
 		return
 	}
 	return
@@ -316,13 +337,13 @@ func (mb *client) WriteMultipleCoils(ctx context.Context, address, quantity uint
 	}
 	respValue := binary.BigEndian.Uint16(response.Data)
 	if address != respValue {
-		err = fmt.Errorf("modbus: response address '%v' does not match request '%v'", respValue, address)
+		err = errorf(ErrResponseMismatch, "modbus: response address '%v' does not match request '%v'", respValue, address)
 		return
 	}
 	results = response.Data[2:]
 	respValue = binary.BigEndian.Uint16(results)
 	if quantity != respValue {
-		err = fmt.Errorf("modbus: response quantity '%v' does not match request '%v'", respValue, quantity)
+		err = errorf(ErrResponseMismatch, "modbus: response quantity '%v' does not match request '%v'", respValue, quantity)
 		return
 	}
 	return
@@ -361,13 +382,13 @@ func (mb *client) WriteMultipleRegisters(ctx context.Context, address, quantity
 	}
 	respValue := binary.BigEndian.Uint16(response.Data)
 	if address != respValue {
-		err = fmt.Errorf("modbus: response address '%v' does not match request '%v'", respValue, address)
+		err = errorf(ErrResponseMismatch, "modbus: response address '%v' does not match request '%v'", respValue, address)
 		return
 	}
 	results = response.Data[2:]
 	respValue = binary.BigEndian.Uint16(results)
 	if quantity != respValue {
-		err = fmt.Errorf("modbus: response quantity '%v' does not match request '%v'", respValue, quantity)
+		err = errorf(ErrResponseMismatch, "modbus: response quantity '%v' does not match request '%v'", respValue, quantity)
 		return
 	}
 	return
@@ -402,17 +423,17 @@ func (mb *client) MaskWriteRegister(ctx context.Context, address, andMask, orMas
 	}
 	respValue := binary.BigEndian.Uint16(response.Data)
 	if address != respValue {
-		err = fmt.Errorf("modbus: response address '%v' does not match request '%v'", respValue, address)
+		err = errorf(ErrResponseMismatch, "modbus: response address '%v' does not match request '%v'", respValue, address)
 		return
 	}
 	respValue = binary.BigEndian.Uint16(response.Data[2:])
 	if andMask != respValue {
-		err = fmt.Errorf("modbus: response AND-mask '%v' does not match request '%v'", respValue, andMask)
+		err = errorf(ErrResponseMismatch, "modbus: response AND-mask '%v' does not match request '%v'", respValue, andMask)
 		return
 	}
 	respValue = binary.BigEndian.Uint16(response.Data[4:])
 	if orMask != respValue {
-		err = fmt.Errorf("modbus: response OR-mask '%v' does not match request '%v'", respValue, orMask)
+		err = errorf(ErrResponseMismatch, "modbus: response OR-mask '%v' does not match request '%v'", respValue, orMask)
 		return
 	}
 	results = response.Data[2:]
@@ -485,11 +506,11 @@ func (mb *client) ReadFIFOQueue(ctx context.Context, address uint16) (results []
 		return
 	}
 	if len(response.Data) < 4 {
-		err = fmt.Errorf("modbus: response data size '%v' is less than expected '%v'", len(response.Data), 4)
+		err = errorf(ErrFraming, "modbus: response data size '%v' is less than expected '%v'", len(response.Data), 4)
 		return
 	}
 	count := int(binary.BigEndian.Uint16(response.Data))
-	length := len(response.Data) - 1
+	length := len(response.Data) - 2
 	if count != length {
 		err = &DataSizeError{ExpectedBytes: count, ActualBytes: length}
 		if length < count {
@@ -498,13 +519,44 @@ func (mb *client) ReadFIFOQueue(ctx context.Context, address uint16) (results []
 	}
 	count = int(binary.BigEndian.Uint16(response.Data[2 : count+2]))
 	if count > 31 {
-		err = fmt.Errorf("modbus: fifo count '%v' is greater than expected '%v'", count, 31)
+		err = errorf(ErrFraming, "modbus: fifo count '%v' is greater than expected '%v'", count, 31)
 		return
 	}
 	results = response.Data[4:]
 	return
 }
 
+// Request:
+//
+//	Function code         : 1 byte (0x11)
+//
+// Response:
+//
+//	Function code         : 1 byte (0x11)
+//	Byte count            : 1 byte
+//	Server ID             : device specific
+//	Run indicator status  : 1 byte (0x00 for OFF, 0xFF for ON)
+//	Additional data       : device specific
+func (mb *client) ReportServerID(ctx context.Context) (results []byte, err error) {
+	request := ProtocolDataUnit{
+		FunctionCode: FuncCodeReportServerID,
+	}
+	response, err := mb.send(ctx, &request)
+	if err != nil {
+		return
+	}
+	count := int(response.Data[0])
+	length := len(response.Data) - 1
+	if count != length {
+		err = &DataSizeError{ExpectedBytes: count, ActualBytes: length}
+		if length < count {
+			return
+		}
+	}
+	results = response.Data[1 : count+1]
+	return
+}
+
 // Request:
 //
 //	Function code         : 1 byte (0x2B)
@@ -556,6 +608,13 @@ func (mb *client) ReadDeviceIdentificationSpecificObject(ctx context.Context, ob
 }
 
 func (mb *client) readDeviceIdentificationWithObjectID(ctx context.Context, readDeviceIDCode ReadDeviceIDCode, objectID byte) (map[byte][]byte, error) {
+	results, _, err := mb.readDeviceIdentificationObjects(ctx, readDeviceIDCode, objectID)
+	return results, err
+}
+
+// readDeviceIdentificationObjects reads the objects starting at objectID,
+// following "More Follows", and returns them with the conformity level.
+func (mb *client) readDeviceIdentificationObjects(ctx context.Context, readDeviceIDCode ReadDeviceIDCode, objectID byte) (map[byte][]byte, ConformityLevel, error) {
 	const meiType = meiTypeReadDeviceIdentification
 
 	request := ProtocolDataUnit{
@@ -565,15 +624,16 @@ func (mb *client) readDeviceIdentificationWithObjectID(ctx context.Context, read
 
 	response, err := mb.send(ctx, &request)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	if got, want := len(response.Data), 6; got < want {
-		return nil, fmt.Errorf("missing required headers, got %d, want %d", got, want)
+		return nil, 0, errorf(ErrFraming, "missing required headers, got %d, want %d", got, want)
 	}
 
 	results := make(map[byte][]byte)
 
+	conformityLevel := ConformityLevel(response.Data[2])
 	moreFollows := response.Data[3]
 	nextObjectID := response.Data[4]
 	numObjects := int(response.Data[5])
@@ -586,7 +646,7 @@ func (mb *client) readDeviceIdentificationWithObjectID(ctx context.Context, read
 		// Read object length
 		offset++
 		if len(response.Data)-1 < offset {
-			return nil, fmt.Errorf("missing object length for object #%d", i)
+			return nil, 0, errorf(ErrFraming, "missing object length for object #%d", i)
 		}
 		objectLength := response.Data[offset]
 
@@ -594,7 +654,7 @@ func (mb *client) readDeviceIdentificationWithObjectID(ctx context.Context, read
 		offset++
 		end := offset + int(objectLength)
 		if len(response.Data) < end {
-			return nil, fmt.Errorf("data too short to read object #%d at index %d", i, end)
+			return nil, 0, errorf(ErrFraming, "data too short to read object #%d at index %d", i, end)
 		}
 		objectValue := response.Data[offset:end]
 
@@ -605,29 +665,135 @@ func (mb *client) readDeviceIdentificationWithObjectID(ctx context.Context, read
 	}
 
 	if moreFollows != 0xFF {
-		return results, nil
+		return results, conformityLevel, nil
 	}
 
 	if nextObjectID == 0x00 {
-		return results, nil
+		return results, conformityLevel, nil
 	}
 
-	nextResults, err := mb.readDeviceIdentificationWithObjectID(ctx, readDeviceIDCode, nextObjectID)
+	nextResults, _, err := mb.readDeviceIdentificationObjects(ctx, readDeviceIDCode, nextObjectID)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	for key, val := range nextResults {
 		results[key] = val
 	}
 
-	return results, nil
+	return results, conformityLevel, nil
+}
+
+// IdentifyDevice reads the identification objects of the device up to the
+// highest access level it supports. It starts with a basic stream access and
+// continues with the regular and extended ones as far as the conformity level
+// of the device allows. If the device rejects stream access with an illegal
+// data value or illegal data address exception, the objects are read one by
+// one with individual access.
+func (mb *client) IdentifyDevice(ctx context.Context) (*DeviceIdentification, error) {
+	objects, conformityLevel, err := mb.readDeviceIdentificationObjects(ctx, ReadDeviceIDCodeBasic, ObjectIDVendorName)
+	if err != nil {
+		if errors.Is(err, ErrIllegalDataValue) || errors.Is(err, ErrIllegalDataAddress) {
+			return mb.identifyDeviceByObject(ctx)
+		}
+		return nil, err
+	}
+	identification := &DeviceIdentification{ConformityLevel: conformityLevel, AccessLevel: ReadDeviceIDCodeBasic}
+	for objectID, value := range objects {
+		identification.set(objectID, value)
+	}
+
+	levels := []struct {
+		readDeviceIDCode ReadDeviceIDCode
+		objectID         byte
+	}{
+		{ReadDeviceIDCodeRegular, ObjectIDVendorURL},
+		{ReadDeviceIDCodeExtended, ObjectIDPrivateFirst},
+	}
+	for _, level := range levels {
+		if conformityLevel.AccessLevel() < level.readDeviceIDCode {
+			break
+		}
+		objects, _, err = mb.readDeviceIdentificationObjects(ctx, level.readDeviceIDCode, level.objectID)
+		if err != nil {
+			var mbErr *Error
+			if errors.As(err, &mbErr) {
+				// the device overstated its conformity level
+				break
+			}
+			return nil, err
+		}
+		for objectID, value := range objects {
+			identification.set(objectID, value)
+		}
+		identification.AccessLevel = level.readDeviceIDCode
+	}
+	return identification, nil
+}
+
+// identifyDeviceByObject reads the identification objects one by one. Missing
+// optional objects are skipped, private objects are read until the first
+// missing one.
+func (mb *client) identifyDeviceByObject(ctx context.Context) (*DeviceIdentification, error) {
+	identification := &DeviceIdentification{AccessLevel: ReadDeviceIDCodeSpecific}
+	for objectID := ObjectIDVendorName; objectID <= 0xFF; objectID++ {
+		if objectID == ObjectIDUserApplicationName+1 {
+			objectID = ObjectIDPrivateFirst
+		}
+		objects, conformityLevel, err := mb.readDeviceIdentificationObjects(ctx, ReadDeviceIDCodeSpecific, byte(objectID))
+		var mbErr *Error
+		switch {
+		case err == nil:
+		case !errors.As(err, &mbErr), objectID == ObjectIDVendorName:
+			return nil, err
+		case objectID >= ObjectIDPrivateFirst:
+			return identification, nil
+		default:
+			continue
+		}
+		identification.ConformityLevel = conformityLevel
+		for id, value := range objects {
+			identification.set(id, value)
+		}
+	}
+	return identification, nil
 }
 
 // Helpers
 
-// send sends request and checks possible exception in the response.
+// send passes the request through the interceptor chain to invoke.
+// Errors wrapped by this package are returned as *RequestError.
 func (mb *client) send(ctx context.Context, request *ProtocolDataUnit) (response *ProtocolDataUnit, err error) {
+	slaveID := mb.slaveID()
+	if len(mb.interceptors) == 0 {
+		response, err = mb.invoke(ctx, request)
+	} else {
+		info := &RequestInfo{
+			SlaveID: slaveID,
+			Start:   time.Now(),
+		}
+		response, err = chainInterceptors(mb.interceptors, info, mb.invoke)(ctx, request)
+		if err == nil {
+			// interceptors may return responses not checked by invoke
+			err = mb.checkResponse(request, response)
+		}
+	}
+	if err != nil {
+		err = newRequestError(slaveID, mb.transportAddress(), request, err)
+	}
+	return
+}
+
+// slaveID returns the slave id of the next request if the packager reports it.
+func (mb *client) slaveID() byte {
+	if s, ok := mb.packager.(slaveReporter); ok {
+		return s.slave()
+	}
+	return 0
+}
+
+// invoke sends request and checks possible exception in the response.
+func (mb *client) invoke(ctx context.Context, request *ProtocolDataUnit) (response *ProtocolDataUnit, err error) {
 	aduRequest, err := mb.packager.Encode(request)
 	if err != nil {
 		return
@@ -641,19 +807,53 @@ func (mb *client) send(ctx context.Context, request *ProtocolDataUnit) (response
 	}
 	response, err = mb.packager.Decode(aduResponse)
 	if err != nil {
+		if errors.Is(err, ErrChecksum) {
+			mb.observer().ChecksumError()
+		}
 		return
 	}
+	err = mb.checkResponse(request, response)
+	var mbErr *Error
+	if errors.As(err, &mbErr) {
+		mb.observer().ExceptionReceived(mbErr.FunctionCode, mbErr.ExceptionCode)
+	}
+	return
+}
+
+// checkResponse returns an *Error describing the request if response is an
+// exception, or an error if it has no data.
+func (mb *client) checkResponse(request, response *ProtocolDataUnit) error {
+	if response == nil {
+		return errorf(ErrFraming, "modbus: response is empty")
+	}
 	// Check correct function code returned (exception)
 	if response.FunctionCode != request.FunctionCode {
-		err = responseError(response)
-		return
+		mbErr := responseError(response)
+		mbErr.SlaveID, mbErr.Transport = mb.slaveID(), mb.transportAddress()
+		mbErr.Address, mbErr.Quantity = requestRange(request)
+		return mbErr
 	}
-	if response.Data == nil || len(response.Data) == 0 {
+	if len(response.Data) == 0 {
 		// Empty response
-		err = fmt.Errorf("modbus: response data is empty")
-		return
+		return errorf(ErrFraming, "modbus: response data is empty")
 	}
-	return
+	return nil
+}
+
+// transportAddress returns the address of the transporter if it reports one.
+func (mb *client) transportAddress() string {
+	if a, ok := mb.transporter.(transportAddresser); ok {
+		return a.transportAddress()
+	}
+	return ""
+}
+
+// observer returns the Observer of the transporter if it has one.
+func (mb *client) observer() Observer {
+	if o, ok := mb.transporter.(observed); ok {
+		return o.observer()
+	}
+	return nopObserver{}
 }
 
 // dataBlock creates a sequence of uint16 data.
@@ -677,7 +877,7 @@ func dataBlockSuffix(suffix []byte, value ...uint16) []byte {
 	return data
 }
 
-func responseError(response *ProtocolDataUnit) error {
+func responseError(response *ProtocolDataUnit) *Error {
 	mbError := &Error{FunctionCode: response.FunctionCode}
 	if response.Data != nil && len(response.Data) > 0 {
 		mbError.ExceptionCode = response.Data[0]
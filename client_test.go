@@ -0,0 +1,42 @@
+package modbus
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestReadFIFOQueue(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		expected []byte
+		size     bool
+	}{
+		{"two registers", []byte{0, 6, 0, 2, 0x01, 0xB8, 0x12, 0x84}, []byte{0x01, 0xB8, 0x12, 0x84}, false},
+		{"empty queue", []byte{0, 2, 0, 0}, []byte{}, false},
+		{"short byte count", []byte{0, 4, 0, 2, 0x01, 0xB8, 0x12, 0x84}, []byte{0x01, 0xB8, 0x12, 0x84}, true},
+		{"long byte count", []byte{0, 8, 0, 2, 0x01, 0xB8, 0x12, 0x84}, nil, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := &pduTransporter{respond: func(request *ProtocolDataUnit) *ProtocolDataUnit {
+				return &ProtocolDataUnit{FunctionCode: request.FunctionCode, Data: tc.data}
+			}}
+			client := NewClient2(&rtuPackager{SlaveID: 1}, tr)
+
+			results, err := client.ReadFIFOQueue(context.Background(), 0x04DE)
+			var sizeErr *DataSizeError
+			if errors.As(err, &sizeErr) != tc.size {
+				t.Errorf("expected data size error %v, got %v", tc.size, err)
+			}
+			if !tc.size && err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(results, tc.expected) {
+				t.Errorf("expected [% x], got [% x]", tc.expected, results)
+			}
+		})
+	}
+}
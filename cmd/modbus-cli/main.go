@@ -5,6 +5,7 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/binary"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -93,7 +94,7 @@ func main() {
 	startReg := uint16(*register)
 
 	if *logframe {
-		opt.logger = &debugAdapter{logger}
+		opt.logger = slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
 	}
 
 	var (
@@ -125,8 +126,8 @@ func main() {
 	client := modbus.NewClient(handler)
 
 	result, err := exec(ctx, client, eo, *writeParseOrder, *register, *fnCode, *writeValue, *eType, *quantity, *readDeviceIDCode, *readDeviceIDObject)
-	if err != nil && strings.Contains(err.Error(), "crc") && *ignoreCRCError {
-		logger.Info("ignoring crc error: %+v\n", "error", err)
+	if errors.Is(err, modbus.ErrChecksum) && *ignoreCRCError {
+		logger.Info("ignoring crc error", "error", err)
 	} else if err != nil {
 		logger.Error(err.Error())
 		os.Exit(-1)
@@ -482,7 +483,7 @@ type option struct {
 	slaveID int
 	timeout time.Duration
 
-	logger modbus.Logger
+	logger *slog.Logger
 
 	rtu struct {
 		baudrate int
@@ -519,7 +520,7 @@ func newHandler(o option) (modbus.ClientHandler, error) {
 		h := modbus.NewRTUClientHandler(u.Path)
 		h.Timeout = o.timeout
 		h.SlaveID = byte(o.slaveID)
-		h.Logger = o.logger
+		h.StructuredLogger = o.logger
 		h.BaudRate = o.rtu.baudrate
 		h.DataBits = o.rtu.dataBits
 		h.Parity = o.rtu.parity
@@ -553,12 +554,13 @@ func newHandler(o option) (modbus.ClientHandler, error) {
 		h.SlaveID = byte(o.slaveID)
 		h.LinkRecoveryTimeout = o.tcp.linkRecoveryTimeout
 		h.ProtocolRecoveryTimeout = o.tcp.protocolRecoveryTimeout
-		h.Logger = o.logger
+		h.StructuredLogger = o.logger
 		return h, nil
 	case "udp":
 		h := modbus.NewRTUOverUDPClientHandler(u.Host)
+		h.Timeout = o.timeout
 		h.SlaveID = byte(o.slaveID)
-		h.Logger = o.logger
+		h.StructuredLogger = o.logger
 		return h, nil
 	case "rtutcp":
 		h := modbus.NewRTUOverTCPClientHandler(u.Host)
@@ -566,7 +568,7 @@ func newHandler(o option) (modbus.ClientHandler, error) {
 		h.SlaveID = byte(o.slaveID)
 		h.LinkRecoveryTimeout = o.tcp.linkRecoveryTimeout
 		h.ProtocolRecoveryTimeout = o.tcp.protocolRecoveryTimeout
-		h.Logger = o.logger
+		h.StructuredLogger = o.logger
 		return h, nil
 	}
 
@@ -0,0 +1,82 @@
+package modbus
+
+// Object ids of the device identification objects as defined in
+// https://www.modbus.org/docs/Modbus_Application_Protocol_V1_1b.pdf#page=45
+const (
+	ObjectIDVendorName          = 0x00
+	ObjectIDProductCode         = 0x01
+	ObjectIDMajorMinorRevision  = 0x02
+	ObjectIDVendorURL           = 0x03
+	ObjectIDProductName         = 0x04
+	ObjectIDModelName           = 0x05
+	ObjectIDUserApplicationName = 0x06
+	// ObjectIDPrivateFirst is the id of the first private (vendor specific)
+	// object, all ids up to 0xFF are private.
+	ObjectIDPrivateFirst = 0x80
+)
+
+// ConformityLevel is the identification conformity level reported by a device.
+type ConformityLevel byte
+
+// AccessLevel returns the highest access level supported by stream access,
+// i.e. ReadDeviceIDCodeBasic, ReadDeviceIDCodeRegular or
+// ReadDeviceIDCodeExtended.
+func (c ConformityLevel) AccessLevel() ReadDeviceIDCode {
+	return ReadDeviceIDCode(c & 0x7F)
+}
+
+// IndividualAccess reports whether the device supports reading specific
+// objects in addition to stream access.
+func (c ConformityLevel) IndividualAccess() bool {
+	return c&0x80 != 0
+}
+
+// DeviceIdentification holds the identification objects of a device.
+type DeviceIdentification struct {
+	// Basic objects
+	VendorName         string
+	ProductCode        string
+	MajorMinorRevision string
+
+	// Regular objects
+	VendorURL           string
+	ProductName         string
+	ModelName           string
+	UserApplicationName string
+
+	// Private holds the extended objects, keyed by object id (0x80 to 0xFF).
+	Private map[byte][]byte
+
+	// ConformityLevel is the conformity level reported by the device.
+	ConformityLevel ConformityLevel
+	// AccessLevel is the highest access level the objects were read with,
+	// or ReadDeviceIDCodeSpecific if they were read one by one because the
+	// device rejects stream access.
+	AccessLevel ReadDeviceIDCode
+}
+
+// set stores the value of an object in the matching field. Objects with
+// reserved ids are ignored.
+func (d *DeviceIdentification) set(objectID byte, value []byte) {
+	switch {
+	case objectID == ObjectIDVendorName:
+		d.VendorName = string(value)
+	case objectID == ObjectIDProductCode:
+		d.ProductCode = string(value)
+	case objectID == ObjectIDMajorMinorRevision:
+		d.MajorMinorRevision = string(value)
+	case objectID == ObjectIDVendorURL:
+		d.VendorURL = string(value)
+	case objectID == ObjectIDProductName:
+		d.ProductName = string(value)
+	case objectID == ObjectIDModelName:
+		d.ModelName = string(value)
+	case objectID == ObjectIDUserApplicationName:
+		d.UserApplicationName = string(value)
+	case objectID >= ObjectIDPrivateFirst:
+		if d.Private == nil {
+			d.Private = make(map[byte][]byte)
+		}
+		d.Private[objectID] = value
+	}
+}
@@ -0,0 +1,161 @@
+package modbus
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// identificationResponder answers Read Device Identification requests for
+// objects. Stream access is rejected with an illegal data value exception
+// unless stream is set.
+func identificationResponder(objects map[byte]string, conformityLevel byte, stream bool) func(*ProtocolDataUnit) *ProtocolDataUnit {
+	return func(request *ProtocolDataUnit) *ProtocolDataUnit {
+		readDeviceIDCode, objectID := ReadDeviceIDCode(request.Data[1]), request.Data[2]
+		exception := func(code byte) *ProtocolDataUnit {
+			return &ProtocolDataUnit{FunctionCode: request.FunctionCode | 0x80, Data: []byte{code}}
+		}
+
+		var ids []byte
+		switch readDeviceIDCode {
+		case ReadDeviceIDCodeSpecific:
+			if _, ok := objects[objectID]; !ok {
+				return exception(ExceptionCodeIllegalDataAddress)
+			}
+			ids = []byte{objectID}
+		default:
+			if !stream {
+				return exception(ExceptionCodeIllegalDataValue)
+			}
+			last := map[ReadDeviceIDCode]byte{
+				ReadDeviceIDCodeBasic:    ObjectIDMajorMinorRevision,
+				ReadDeviceIDCodeRegular:  0x7F,
+				ReadDeviceIDCodeExtended: 0xFF,
+			}[readDeviceIDCode]
+			for id := range objects {
+				if id >= objectID && id <= last {
+					ids = append(ids, id)
+				}
+			}
+			sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		}
+
+		data := []byte{byte(meiTypeReadDeviceIdentification), byte(readDeviceIDCode), conformityLevel, 0, 0, byte(len(ids))}
+		for _, id := range ids {
+			data = append(data, id, byte(len(objects[id])))
+			data = append(data, objects[id]...)
+		}
+		return &ProtocolDataUnit{FunctionCode: request.FunctionCode, Data: data}
+	}
+}
+
+func TestIdentifyDevice(t *testing.T) {
+	objects := map[byte]string{
+		ObjectIDVendorName:         "grid-x",
+		ObjectIDProductCode:        "gx-1",
+		ObjectIDMajorMinorRevision: "1.2",
+		ObjectIDProductName:        "meter",
+		ObjectIDModelName:          "m1",
+		0x80:                       "serial",
+		0x81:                       "firmware",
+	}
+	full := DeviceIdentification{
+		VendorName:         "grid-x",
+		ProductCode:        "gx-1",
+		MajorMinorRevision: "1.2",
+		ProductName:        "meter",
+		ModelName:          "m1",
+		Private:            map[byte][]byte{0x80: []byte("serial"), 0x81: []byte("firmware")},
+	}
+
+	tests := []struct {
+		name            string
+		conformityLevel byte
+		stream          bool
+		expected        DeviceIdentification
+		requests        int
+	}{
+		{
+			name:            "extended stream access",
+			conformityLevel: 0x83,
+			stream:          true,
+			expected:        full,
+			requests:        3,
+		},
+		{
+			name:            "basic stream access",
+			conformityLevel: 0x01,
+			stream:          true,
+			expected: DeviceIdentification{
+				VendorName:         "grid-x",
+				ProductCode:        "gx-1",
+				MajorMinorRevision: "1.2",
+			},
+			requests: 1,
+		},
+		{
+			name:            "individual access",
+			conformityLevel: 0x83,
+			expected:        full,
+			// rejected stream, 7 standard and 3 private objects
+			requests: 11,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &pduTransporter{respond: identificationResponder(objects, tt.conformityLevel, tt.stream)}
+			identification, err := NewClient2(&rtuPackager{SlaveID: 1}, tr).(DeviceIdentifier).IdentifyDevice(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := tt.expected
+			expected.ConformityLevel = ConformityLevel(tt.conformityLevel)
+			expected.AccessLevel = expected.ConformityLevel.AccessLevel()
+			if !tt.stream {
+				expected.AccessLevel = ReadDeviceIDCodeSpecific
+			}
+			if !reflect.DeepEqual(*identification, expected) {
+				t.Fatalf("expected %+v, got %+v", expected, *identification)
+			}
+			if len(tr.requests) != tt.requests {
+				t.Fatalf("expected %d requests, got %d", tt.requests, len(tr.requests))
+			}
+		})
+	}
+}
+
+func TestIdentifyDeviceUnsupported(t *testing.T) {
+	tr := &pduTransporter{respond: identificationResponder(map[byte]string{}, 0x81, false)}
+	_, err := NewClient2(&rtuPackager{SlaveID: 1}, tr).(DeviceIdentifier).IdentifyDevice(context.Background())
+	if !errors.Is(err, ErrIllegalDataAddress) {
+		t.Fatalf("expected illegal data address, got %v", err)
+	}
+}
+
+func TestIdentifyDeviceException(t *testing.T) {
+	for _, code := range []byte{ExceptionCodeIllegalFunction, ExceptionCodeGatewayTargetDeviceFailedToRespond} {
+		tr := &pduTransporter{respond: func(request *ProtocolDataUnit) *ProtocolDataUnit {
+			return &ProtocolDataUnit{FunctionCode: request.FunctionCode | 0x80, Data: []byte{code}}
+		}}
+		_, err := NewClient2(&rtuPackager{SlaveID: 1}, tr).(DeviceIdentifier).IdentifyDevice(context.Background())
+		var mbErr *Error
+		if !errors.As(err, &mbErr) || mbErr.ExceptionCode != code {
+			t.Errorf("expected exception %v, got %v", code, err)
+		}
+		// only rejected stream access falls back to individual access
+		if len(tr.requests) != 1 {
+			t.Errorf("exception %v: expected 1 request, got %d", code, len(tr.requests))
+		}
+	}
+}
+
+func TestConformityLevel(t *testing.T) {
+	if level := ConformityLevel(0x82); level.AccessLevel() != ReadDeviceIDCodeRegular || !level.IndividualAccess() {
+		t.Fatalf("unexpected access of %#x", byte(level))
+	}
+	if level := ConformityLevel(0x01); level.AccessLevel() != ReadDeviceIDCodeBasic || level.IndividualAccess() {
+		t.Fatalf("unexpected access of %#x", byte(level))
+	}
+}
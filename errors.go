@@ -0,0 +1,244 @@
+package modbus
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+
+	"github.com/grid-x/serial"
+)
+
+// Sentinel errors matched by the errors returned from clients, transporters
+// and packagers, to be tested with errors.Is.
+var (
+	// ErrTimeout is matched by errors caused by a read or write timeout.
+	ErrTimeout = errors.New("modbus: timeout")
+	// ErrChecksum is matched by errors caused by a CRC or LRC mismatch.
+	ErrChecksum = errors.New("modbus: checksum mismatch")
+	// ErrFraming is matched by errors caused by a malformed frame, e.g. an
+	// invalid length or missing start and end characters.
+	ErrFraming = errors.New("modbus: framing error")
+	// ErrResponseMismatch is matched by errors caused by a response that does
+	// not belong to the request, e.g. a different transaction id, slave id,
+	// address or value.
+	ErrResponseMismatch = errors.New("modbus: response does not match request")
+	// ErrConnectionLost is matched by errors caused by a connection closed or
+	// reset by the remote side, or a link that could not be recovered.
+	ErrConnectionLost = errors.New("modbus: connection lost")
+)
+
+// Sentinel errors matched by the *Error of the exception code, regardless of
+// the function code.
+var (
+	ErrIllegalFunction                    error = &Error{ExceptionCode: ExceptionCodeIllegalFunction}
+	ErrIllegalDataAddress                 error = &Error{ExceptionCode: ExceptionCodeIllegalDataAddress}
+	ErrIllegalDataValue                   error = &Error{ExceptionCode: ExceptionCodeIllegalDataValue}
+	ErrServerDeviceFailure                error = &Error{ExceptionCode: ExceptionCodeServerDeviceFailure}
+	ErrAcknowledge                        error = &Error{ExceptionCode: ExceptionCodeAcknowledge}
+	ErrServerDeviceBusy                   error = &Error{ExceptionCode: ExceptionCodeServerDeviceBusy}
+	ErrMemoryParityError                  error = &Error{ExceptionCode: ExceptionCodeMemoryParityError}
+	ErrGatewayPathUnavailable             error = &Error{ExceptionCode: ExceptionCodeGatewayPathUnavailable}
+	ErrGatewayTargetDeviceFailedToRespond error = &Error{ExceptionCode: ExceptionCodeGatewayTargetDeviceFailedToRespond}
+)
+
+// Is reports whether target is an *Error with the same exception code and,
+// unless the function code of target is zero, the same function code.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok {
+		return false
+	}
+	return t.ExceptionCode == e.ExceptionCode && (t.FunctionCode == 0 || t.FunctionCode == e.FunctionCode)
+}
+
+// ChecksumError is returned by packagers if the CRC or LRC of a response does
+// not match its content. It matches ErrChecksum.
+type ChecksumError struct {
+	// Kind is either "crc" or "lrc".
+	Kind          string
+	Got, Expected uint16
+}
+
+// Error implements the error interface
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("modbus: response %s '%v' does not match expected '%v'", e.Kind, e.Got, e.Expected)
+}
+
+// Is reports whether target is ErrChecksum.
+func (e *ChecksumError) Is(target error) bool {
+	return target == ErrChecksum
+}
+
+// kindError is an error keeping its own message while matching one of the
+// sentinel errors.
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+func (e *kindError) Unwrap() []error {
+	return []error{e.kind, e.err}
+}
+
+// errorf formats an error like fmt.Errorf that additionally matches kind.
+func errorf(kind error, format string, a ...any) error {
+	return &kindError{kind: kind, err: fmt.Errorf(format, a...)}
+}
+
+// transportError makes a transport error match ErrTimeout or
+// ErrConnectionLost if appropriate.
+func transportError(err error) error {
+	switch {
+	case err == nil, errors.Is(err, ErrTimeout), errors.Is(err, ErrConnectionLost):
+		return err
+	case isTimeout(err):
+		return &kindError{kind: ErrTimeout, err: err}
+	case isConnectionLost(err):
+		return &kindError{kind: ErrConnectionLost, err: err}
+	}
+	return err
+}
+
+// isTimeout reports whether err is caused by a read or write timeout.
+func isTimeout(err error) bool {
+	if errors.Is(err, ErrTimeout) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, serial.ErrTimeout)
+}
+
+// isConnectionLost reports whether err is caused by a closed connection.
+func isConnectionLost(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, net.ErrClosed)
+}
+
+// transportAddresser is implemented by the transporters of this package and
+// reports the address of the remote device or serial port.
+type transportAddresser interface {
+	transportAddress() string
+}
+
+// RequestError is returned by the client methods if a request fails with an
+// error of this package matching ErrTimeout, ErrConnectionLost, ErrFraming and
+// the like. It describes the request and wraps the underlying error. Exception
+// responses are returned as *Error describing the request, and other errors,
+// e.g. context.Canceled, unchanged.
+type RequestError struct {
+	SlaveID      byte
+	FunctionCode byte
+	// Address is the starting address of the request, if its function has one.
+	Address uint16
+	// Quantity is the number of coils or registers of the request, or zero if
+	// its function has none.
+	Quantity uint16
+	// Transport is the address of the remote device or serial port, if known.
+	Transport string
+	Err       error
+}
+
+// Error implements the error interface
+func (e *RequestError) Error() string {
+	s := fmt.Sprintf("%v (slave '%v'", e.Err, e.SlaveID)
+	if hasAddress(e.FunctionCode) {
+		s += fmt.Sprintf(", address '%v'", e.Address)
+	}
+	if e.Quantity > 0 {
+		s += fmt.Sprintf(", quantity '%v'", e.Quantity)
+	}
+	if e.Transport != "" {
+		s += fmt.Sprintf(", transport '%v'", e.Transport)
+	}
+	return s + ")"
+}
+
+// Unwrap returns the underlying error.
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// newRequestError describes the request in a *RequestError wrapping err, if
+// err is wrapped by this package already. Other errors are returned unchanged,
+// so that they can still be compared and type asserted.
+func newRequestError(slaveID byte, transport string, request *ProtocolDataUnit, err error) error {
+	if _, ok := err.(*kindError); !ok {
+		return err
+	}
+	e := &RequestError{SlaveID: slaveID, FunctionCode: request.FunctionCode, Transport: transport, Err: err}
+	e.Address, e.Quantity = requestRange(request)
+	return e
+}
+
+// requestRange returns the starting address and the number of coils or
+// registers of request, or zero if its function has none.
+func requestRange(request *ProtocolDataUnit) (address, quantity uint16) {
+	if hasAddress(request.FunctionCode) && len(request.Data) >= 2 {
+		address = binary.BigEndian.Uint16(request.Data)
+	}
+	switch request.FunctionCode {
+	case FuncCodeReadCoils, FuncCodeReadDiscreteInputs, FuncCodeReadHoldingRegisters, FuncCodeReadInputRegisters,
+		FuncCodeWriteMultipleCoils, FuncCodeWriteMultipleRegisters, FuncCodeReadWriteMultipleRegisters:
+		if len(request.Data) >= 4 {
+			quantity = binary.BigEndian.Uint16(request.Data[2:])
+		}
+	case FuncCodeWriteSingleCoil, FuncCodeWriteSingleRegister, FuncCodeMaskWriteRegister:
+		quantity = 1
+	}
+	return
+}
+
+// hasAddress reports whether requests of the function start with an address.
+func hasAddress(functionCode byte) bool {
+	switch functionCode {
+	case FuncCodeReadCoils, FuncCodeReadDiscreteInputs, FuncCodeReadHoldingRegisters, FuncCodeReadInputRegisters,
+		FuncCodeWriteSingleCoil, FuncCodeWriteSingleRegister, FuncCodeWriteMultipleCoils, FuncCodeWriteMultipleRegisters,
+		FuncCodeReadWriteMultipleRegisters, FuncCodeMaskWriteRegister, FuncCodeReadFIFOQueue:
+		return true
+	}
+	return false
+}
+
+// IsDeviceException reports whether err is caused by an exception response of
+// the device itself, i.e. any exception but the gateway ones.
+func IsDeviceException(err error) bool {
+	var mbErr *Error
+	return errors.As(err, &mbErr) && !isGatewayException(mbErr.ExceptionCode)
+}
+
+// IsGatewayException reports whether err is caused by an exception response of
+// a gateway, i.e. the gateway path is unavailable or the target device failed
+// to respond.
+func IsGatewayException(err error) bool {
+	var mbErr *Error
+	return errors.As(err, &mbErr) && isGatewayException(mbErr.ExceptionCode)
+}
+
+func isGatewayException(exceptionCode byte) bool {
+	return exceptionCode == ExceptionCodeGatewayPathUnavailable ||
+		exceptionCode == ExceptionCodeGatewayTargetDeviceFailedToRespond
+}
+
+// IsTransportError reports whether err is caused by the transport rather than
+// the device: a timeout, a lost connection, a network error or a corrupted or
+// mismatched response.
+func IsTransportError(err error) bool {
+	if errors.Is(err, ErrTimeout) || errors.Is(err, ErrConnectionLost) || errors.Is(err, ErrChecksum) ||
+		errors.Is(err, ErrFraming) || errors.Is(err, ErrResponseMismatch) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
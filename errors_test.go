@@ -0,0 +1,251 @@
+package modbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestErrorIs(t *testing.T) {
+	err := fmt.Errorf("wrapped: %w", &Error{FunctionCode: 0x83, ExceptionCode: ExceptionCodeIllegalDataAddress})
+
+	if !errors.Is(err, ErrIllegalDataAddress) {
+		t.Fatal("expected exception to match ErrIllegalDataAddress")
+	}
+	if errors.Is(err, ErrIllegalDataValue) {
+		t.Fatal("expected exception not to match ErrIllegalDataValue")
+	}
+	if !errors.Is(err, &Error{FunctionCode: 0x83, ExceptionCode: ExceptionCodeIllegalDataAddress}) {
+		t.Fatal("expected exception to match the same function code")
+	}
+	if errors.Is(err, &Error{FunctionCode: 0x81, ExceptionCode: ExceptionCodeIllegalDataAddress}) {
+		t.Fatal("expected exception not to match a different function code")
+	}
+}
+
+func TestPackagerErrorKinds(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		kind error
+	}{
+		{
+			name: "rtu crc",
+			err: func() error {
+				_, err := (&rtuPackager{}).Decode([]byte{0x01, 0x10, 0x8A, 0x00, 0x00, 0x03, 0xAA, 0x11})
+				return err
+			}(),
+			kind: ErrChecksum,
+		},
+		{
+			name: "ascii lrc",
+			err: func() error {
+				_, err := (&asciiPackager{}).Decode([]byte(":010300000001FA\r\n"))
+				return err
+			}(),
+			kind: ErrChecksum,
+		},
+		{
+			name: "rtu slave id",
+			err:  (&rtuPackager{}).Verify([]byte{0x01, 0x03, 0, 0, 0, 1, 0, 0}, []byte{0x02, 0x03, 0x02, 0, 0, 0, 0}),
+			kind: ErrResponseMismatch,
+		},
+		{
+			name: "rtu response length",
+			err:  (&rtuPackager{}).Verify([]byte{0x01, 0x03, 0, 0, 0, 1, 0, 0}, []byte{0x01, 0x03}),
+			kind: ErrFraming,
+		},
+		{
+			name: "ascii start character",
+			err:  (&asciiPackager{}).Verify([]byte(":010300000001FB\r\n"), []byte(";01030200007A\r\n")),
+			kind: ErrFraming,
+		},
+		{
+			name: "tcp transaction id",
+			err:  verify([]byte{0, 1, 0, 0, 0, 2, 1, 3}, []byte{0, 2, 0, 0, 0, 3, 1, 3, 0}),
+			kind: ErrResponseMismatch,
+		},
+		{
+			name: "tcp unit id",
+			err:  verify([]byte{0, 1, 0, 0, 0, 2, 1, 3}, []byte{0, 1, 0, 0, 0, 3, 2, 3, 0}),
+			kind: ErrResponseMismatch,
+		},
+		{
+			name: "tcp header length",
+			err:  ErrTCPHeaderLength(0),
+			kind: ErrFraming,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !errors.Is(tt.err, tt.kind) {
+				t.Fatalf("expected %v to match %v", tt.err, tt.kind)
+			}
+		})
+	}
+
+	var checksumErr *ChecksumError
+	if !errors.As(tests[0].err, &checksumErr) || checksumErr.Kind != "crc" {
+		t.Fatalf("expected *ChecksumError, got %v", tests[0].err)
+	}
+	var tidErr *TransactionIDMismatchError
+	if !errors.As(tests[5].err, &tidErr) || tidErr.Got != 2 || tidErr.Expected != 1 {
+		t.Fatalf("expected *TransactionIDMismatchError, got %v", tests[5].err)
+	}
+}
+
+func TestTransportError(t *testing.T) {
+	timeout := &net.OpError{Op: "read", Net: "tcp", Err: &timeoutError{}}
+	tests := []struct {
+		name string
+		err  error
+		kind error
+	}{
+		{name: "net timeout", err: timeout, kind: ErrTimeout},
+		{name: "deadline", err: fmt.Errorf("read: %w", context.DeadlineExceeded), kind: ErrTimeout},
+		{name: "eof", err: io.EOF, kind: ErrConnectionLost},
+		{name: "reset", err: &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}, kind: ErrConnectionLost},
+		{name: "closed", err: net.ErrClosed, kind: ErrConnectionLost},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := transportError(tt.err)
+			if !errors.Is(err, tt.kind) {
+				t.Fatalf("expected %v to match %v", err, tt.kind)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v to still match %v", err, tt.err)
+			}
+			if err.Error() != tt.err.Error() {
+				t.Fatalf("expected message %q, got %q", tt.err.Error(), err.Error())
+			}
+		})
+	}
+
+	if err := transportError(ErrFraming); err != ErrFraming {
+		t.Fatalf("expected other errors to be unchanged, got %v", err)
+	}
+}
+
+func TestTCPTransporterTimeout(t *testing.T) {
+	srvConn, cliConn := net.Pipe()
+	t.Cleanup(func() { srvConn.Close(); cliConn.Close() })
+	go io.Copy(io.Discard, srvConn)
+
+	handler := NewTCPClientHandler("irrelevant", WithDialer(
+		func(context.Context, string, string) (net.Conn, error) { return cliConn, nil },
+	))
+	handler.Timeout = 50 * time.Millisecond
+	_, err := handler.Send(context.Background(), []byte{0, 1, 0, 0, 0, 2, 0, 3})
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Fatalf("expected underlying timeout error, got %v", err)
+	}
+}
+
+type timeoutError struct{}
+
+func (*timeoutError) Error() string   { return "i/o timeout" }
+func (*timeoutError) Timeout() bool   { return true }
+func (*timeoutError) Temporary() bool { return true }
+
+type addressedTransporter struct {
+	pduTransporter
+	address string
+}
+
+func (tr *addressedTransporter) transportAddress() string {
+	return tr.address
+}
+
+func TestRequestError(t *testing.T) {
+	tr := &addressedTransporter{address: "/dev/ttyUSB0"}
+	tr.respond = func(request *ProtocolDataUnit) *ProtocolDataUnit {
+		return &ProtocolDataUnit{FunctionCode: request.FunctionCode | 0x80, Data: []byte{ExceptionCodeIllegalDataAddress}}
+	}
+	client := NewClient2(&rtuPackager{SlaveID: 7}, tr)
+
+	// exception responses keep their type
+	_, err := client.ReadHoldingRegisters(context.Background(), 100, 2)
+	mbErr, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("expected *Error, got %v", err)
+	}
+	want := Error{FunctionCode: FuncCodeReadHoldingRegisters | 0x80, ExceptionCode: ExceptionCodeIllegalDataAddress,
+		SlaveID: 7, Address: 100, Quantity: 2, Transport: "/dev/ttyUSB0"}
+	if *mbErr != want {
+		t.Fatalf("expected %+v, got %+v", want, *mbErr)
+	}
+	if !errors.Is(err, ErrIllegalDataAddress) || !IsDeviceException(err) || IsGatewayException(err) || IsTransportError(err) {
+		t.Fatalf("unexpected classification of %v", err)
+	}
+
+	_, err = client.WriteSingleCoil(context.Background(), 3, 0xFF00)
+	if !errors.As(err, &mbErr) || mbErr.Address != 3 || mbErr.Quantity != 1 {
+		t.Fatalf("expected single coil request, got %v", err)
+	}
+
+	// errors wrapped by this package are described by a *RequestError
+	tr.respond = func(request *ProtocolDataUnit) *ProtocolDataUnit {
+		return &ProtocolDataUnit{FunctionCode: request.FunctionCode}
+	}
+	_, err = client.ReadHoldingRegisters(context.Background(), 100, 2)
+	var reqErr *RequestError
+	if !errors.As(err, &reqErr) {
+		t.Fatalf("expected *RequestError, got %v", err)
+	}
+	wantReq := RequestError{SlaveID: 7, FunctionCode: FuncCodeReadHoldingRegisters, Address: 100, Quantity: 2, Transport: "/dev/ttyUSB0"}
+	if reqErr.SlaveID != wantReq.SlaveID || reqErr.FunctionCode != wantReq.FunctionCode || reqErr.Address != wantReq.Address ||
+		reqErr.Quantity != wantReq.Quantity || reqErr.Transport != wantReq.Transport {
+		t.Fatalf("expected %+v, got %+v", wantReq, *reqErr)
+	}
+	const msg = "modbus: response data is empty (slave '7', address '100', quantity '2', transport '/dev/ttyUSB0')"
+	if err.Error() != msg {
+		t.Fatalf("expected %q, got %q", msg, err.Error())
+	}
+	if !errors.Is(err, ErrFraming) || !IsTransportError(err) {
+		t.Fatalf("unexpected classification of %v", err)
+	}
+
+	// other errors are returned unchanged
+	client = NewClient(&scriptedHandler{errs: []error{io.ErrUnexpectedEOF}})
+	if _, err = client.ReadHoldingRegisters(context.Background(), 100, 2); err != io.ErrUnexpectedEOF {
+		t.Fatalf("expected unexpected EOF, got %v", err)
+	}
+}
+
+func TestErrorClassification(t *testing.T) {
+	tests := []struct {
+		name                       string
+		err                        error
+		device, gateway, transport bool
+	}{
+		{name: "device exception", err: &Error{FunctionCode: 0x83, ExceptionCode: ExceptionCodeServerDeviceBusy}, device: true},
+		{name: "gateway exception", err: &Error{FunctionCode: 0x83, ExceptionCode: ExceptionCodeGatewayTargetDeviceFailedToRespond}, gateway: true},
+		{name: "timeout", err: transportError(&net.OpError{Op: "read", Err: &timeoutError{}}), transport: true},
+		{name: "checksum", err: &ChecksumError{Kind: "crc"}, transport: true},
+		{name: "other", err: errors.New("other")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := &RequestError{Err: tt.err}
+			if got := IsDeviceException(err); got != tt.device {
+				t.Errorf("IsDeviceException: expected %v, got %v", tt.device, got)
+			}
+			if got := IsGatewayException(err); got != tt.gateway {
+				t.Errorf("IsGatewayException: expected %v, got %v", tt.gateway, got)
+			}
+			if got := IsTransportError(err); got != tt.transport {
+				t.Errorf("IsTransportError: expected %v, got %v", tt.transport, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package modbus
+
+import (
+	"context"
+	"time"
+)
+
+// RequestInfo describes a request passing through the interceptor chain.
+type RequestInfo struct {
+	// SlaveID is the slave id the request is addressed to.
+	SlaveID byte
+	// Start is the time the request entered the interceptor chain.
+	Start time.Time
+}
+
+// Invoker performs a request and returns the response PDU. Modbus exception
+// responses are returned as *Error.
+type Invoker func(ctx context.Context, request *ProtocolDataUnit) (response *ProtocolDataUnit, err error)
+
+// Interceptor intercepts every request of a client. It may inspect or modify
+// the request before passing it on to invoker, inspect or modify the response
+// or error returned by invoker, or short-circuit the call by returning a
+// response without calling invoker at all. The response of the chain is
+// checked like the one of the transport, e.g. exception responses are returned
+// as *Error.
+type Interceptor func(ctx context.Context, info *RequestInfo, request *ProtocolDataUnit, invoker Invoker) (response *ProtocolDataUnit, err error)
+
+// WithInterceptors returns a ClientOption that adds interceptors to the client.
+// Interceptors are called in the given order, i.e. the first interceptor is the
+// outermost one and sees the request first and the response last. Repeated use
+// appends to the chain.
+func WithInterceptors(interceptors ...Interceptor) ClientOption {
+	return func(mb *client) {
+		mb.interceptors = append(mb.interceptors, interceptors...)
+	}
+}
+
+// chainInterceptors composes the interceptors around invoker.
+func chainInterceptors(interceptors []Interceptor, info *RequestInfo, invoker Invoker) Invoker {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], invoker
+		invoker = func(ctx context.Context, request *ProtocolDataUnit) (*ProtocolDataUnit, error) {
+			return interceptor(ctx, info, request, next)
+		}
+	}
+	return invoker
+}
@@ -0,0 +1,151 @@
+package modbus
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// pduTransporter answers every RTU request with the PDU returned by respond.
+type pduTransporter struct {
+	requests []*ProtocolDataUnit
+	respond  func(request *ProtocolDataUnit) *ProtocolDataUnit
+}
+
+func (tr *pduTransporter) Send(_ context.Context, aduRequest []byte) ([]byte, error) {
+	var packager rtuPackager
+	request, err := packager.Decode(aduRequest)
+	if err != nil {
+		return nil, err
+	}
+	tr.requests = append(tr.requests, request)
+	packager.SlaveID = aduRequest[0]
+	return packager.Encode(tr.respond(request))
+}
+
+func TestInterceptorChainOrder(t *testing.T) {
+	tr := &pduTransporter{respond: func(*ProtocolDataUnit) *ProtocolDataUnit {
+		return &ProtocolDataUnit{FunctionCode: FuncCodeReadHoldingRegisters, Data: []byte{2, 0xCA, 0xFE}}
+	}}
+
+	var calls []string
+	record := func(name string) Interceptor {
+		return func(ctx context.Context, info *RequestInfo, request *ProtocolDataUnit, invoker Invoker) (*ProtocolDataUnit, error) {
+			if info.SlaveID != 17 {
+				t.Errorf("%s: expected slave id 17, got %v", name, info.SlaveID)
+			}
+			if info.Start.IsZero() {
+				t.Errorf("%s: expected start time to be set", name)
+			}
+			calls = append(calls, name+" before")
+			response, err := invoker(ctx, request)
+			calls = append(calls, name+" after")
+			return response, err
+		}
+	}
+
+	client := NewClient2(&rtuPackager{SlaveID: 17}, tr,
+		WithInterceptors(record("first"), record("second")),
+		WithInterceptors(record("third")),
+	)
+	results, err := client.ReadHoldingRegisters(context.Background(), 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(results, []byte{0xCA, 0xFE}) {
+		t.Fatalf("unexpected results % x", results)
+	}
+	want := []string{"first before", "second before", "third before", "third after", "second after", "first after"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("expected calls %v, got %v", want, calls)
+	}
+}
+
+func TestInterceptorShortCircuitAndModify(t *testing.T) {
+	tr := &pduTransporter{respond: func(request *ProtocolDataUnit) *ProtocolDataUnit {
+		return &ProtocolDataUnit{FunctionCode: request.FunctionCode, Data: request.Data}
+	}}
+
+	// reject all writes without touching the transport
+	errReadOnly := errors.New("read only")
+	guard := func(ctx context.Context, info *RequestInfo, request *ProtocolDataUnit, invoker Invoker) (*ProtocolDataUnit, error) {
+		if request.FunctionCode == FuncCodeWriteSingleRegister {
+			return nil, errReadOnly
+		}
+		return invoker(ctx, request)
+	}
+	// move all coils by an offset of 100
+	offset := func(ctx context.Context, info *RequestInfo, request *ProtocolDataUnit, invoker Invoker) (*ProtocolDataUnit, error) {
+		if request.FunctionCode == FuncCodeWriteSingleCoil {
+			request = &ProtocolDataUnit{FunctionCode: request.FunctionCode, Data: dataBlock(100, 0xFF00)}
+		}
+		response, err := invoker(ctx, request)
+		if err == nil && response.FunctionCode == FuncCodeWriteSingleCoil {
+			response.Data = dataBlock(0, 0xFF00)
+		}
+		return response, err
+	}
+
+	client := NewClient2(&rtuPackager{SlaveID: 1}, tr, WithInterceptors(guard, offset))
+	ctx := context.Background()
+	if _, err := client.WriteSingleRegister(ctx, 1, 2); !errors.Is(err, errReadOnly) {
+		t.Fatalf("expected write to be rejected, got %v", err)
+	}
+	if len(tr.requests) != 0 {
+		t.Fatalf("expected no request to reach the transport, got %v", tr.requests)
+	}
+	if _, err := client.WriteSingleCoil(ctx, 0, 0xFF00); err != nil {
+		t.Fatal(err)
+	}
+	if got := tr.requests[0].Data; !reflect.DeepEqual(got, dataBlock(100, 0xFF00)) {
+		t.Fatalf("expected modified request, got % x", got)
+	}
+}
+
+func TestInterceptorSeesException(t *testing.T) {
+	tr := &pduTransporter{respond: func(request *ProtocolDataUnit) *ProtocolDataUnit {
+		return &ProtocolDataUnit{FunctionCode: request.FunctionCode | 0x80, Data: []byte{ExceptionCodeIllegalDataAddress}}
+	}}
+
+	var seen error
+	observe := func(ctx context.Context, info *RequestInfo, request *ProtocolDataUnit, invoker Invoker) (*ProtocolDataUnit, error) {
+		response, err := invoker(ctx, request)
+		seen = err
+		return response, err
+	}
+
+	client := NewClient2(&rtuPackager{SlaveID: 1}, tr, WithInterceptors(observe))
+	_, err := client.ReadCoils(context.Background(), 0, 1)
+	var mbErr *Error
+	if !errors.As(seen, &mbErr) || mbErr.ExceptionCode != ExceptionCodeIllegalDataAddress {
+		t.Fatalf("expected interceptor to see exception, got %v", seen)
+	}
+	if err != seen {
+		t.Fatalf("expected client to return the intercepted error, got %v", err)
+	}
+}
+
+func TestInterceptorResponseChecked(t *testing.T) {
+	tests := []struct {
+		name     string
+		response *ProtocolDataUnit
+		check    func(err error) bool
+	}{
+		{"nil", nil, func(err error) bool { return errors.Is(err, ErrFraming) }},
+		{"empty", &ProtocolDataUnit{FunctionCode: FuncCodeReadHoldingRegisters}, func(err error) bool { return errors.Is(err, ErrFraming) }},
+		{"exception", &ProtocolDataUnit{FunctionCode: FuncCodeReadHoldingRegisters | 0x80, Data: []byte{ExceptionCodeServerDeviceBusy}},
+			func(err error) bool { return errors.Is(err, ErrServerDeviceBusy) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shortCircuit := func(context.Context, *RequestInfo, *ProtocolDataUnit, Invoker) (*ProtocolDataUnit, error) {
+				return tt.response, nil
+			}
+			client := NewClient2(&rtuPackager{SlaveID: 1}, &pduTransporter{}, WithInterceptors(shortCircuit))
+			if _, err := client.ReadHoldingRegisters(context.Background(), 0, 1); !tt.check(err) {
+				t.Fatalf("expected checked response, got %v", err)
+			}
+		})
+	}
+}
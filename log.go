@@ -0,0 +1,210 @@
+package modbus
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Attribute keys of the structured log records written by the transporters.
+const (
+	LogKeySlaveID       = "slave_id"
+	LogKeyFunctionCode  = "function_code"
+	LogKeyTransactionID = "transaction_id"
+	LogKeyDirection     = "direction"
+	LogKeyPayload       = "payload"
+	LogKeyError         = "error"
+	LogKeyDuration      = "duration"
+	LogKeyAction        = "action"
+)
+
+// Values of the LogKeyDirection attribute.
+const (
+	LogDirectionSend = "send"
+	LogDirectionRecv = "recv"
+)
+
+// Values of the LogKeyAction attribute describing how a transporter recovers
+// from an error.
+const (
+	LogActionClose     = "close"
+	LogActionKeep      = "keep"
+	LogActionRetry     = "retry"
+	LogActionReconnect = "reconnect"
+)
+
+// discardLogger is used by transporters without a logger.
+var discardLogger = slog.New(discardHandler{})
+
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// NewPrintfHandler returns a slog.Handler that writes all records with the
+// given Printf Logger, as message followed by key=value pairs. Transporters
+// use it to support their Logger field.
+func NewPrintfHandler(logger Logger) slog.Handler {
+	return &printfHandler{logger: logger}
+}
+
+type printfHandler struct {
+	logger Logger
+	attrs  string
+	group  string
+}
+
+func (h *printfHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (h *printfHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	b.WriteString(r.Message)
+	b.WriteString(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		appendAttr(&b, h.group, a)
+		return true
+	})
+	h.logger.Printf("%s", b.String())
+	return nil
+}
+
+func (h *printfHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var b strings.Builder
+	b.WriteString(h.attrs)
+	for _, a := range attrs {
+		appendAttr(&b, h.group, a)
+	}
+	return &printfHandler{logger: h.logger, attrs: b.String(), group: h.group}
+}
+
+func (h *printfHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &printfHandler{logger: h.logger, attrs: h.attrs, group: h.group + name + "."}
+}
+
+func appendAttr(b *strings.Builder, group string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			group += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			appendAttr(b, group, ga)
+		}
+		return
+	}
+	fmt.Fprintf(b, " %s%s=%v", group, a.Key, a.Value)
+}
+
+// printfLogger builds the logger of a transporter once per Printf Logger
+// instead of once per log record.
+type printfLogger struct {
+	cached atomic.Pointer[printfLoggerEntry]
+}
+
+type printfLoggerEntry struct {
+	printf Logger
+	logger *slog.Logger
+}
+
+// of returns the logger of a transporter: the structured logger if set, else
+// the Printf logger wrapped by NewPrintfHandler, else a logger discarding all
+// records.
+func (c *printfLogger) of(structured *slog.Logger, printf Logger) *slog.Logger {
+	switch {
+	case structured != nil:
+		return structured
+	case printf == nil:
+		return discardLogger
+	}
+	if e := c.cached.Load(); e != nil && sameLogger(e.printf, printf) {
+		return e.logger
+	}
+	logger := slog.New(NewPrintfHandler(printf))
+	c.cached.Store(&printfLoggerEntry{printf: printf, logger: logger})
+	return logger
+}
+
+// sameLogger reports whether a and b are the same Printf Logger. Loggers of
+// types that are not comparable are never the same.
+func sameLogger(a, b Logger) bool {
+	t := reflect.TypeOf(a)
+	return t == reflect.TypeOf(b) && t.Comparable() && a == b
+}
+
+// hexPayload logs a frame as space separated hex bytes.
+type hexPayload []byte
+
+func (p hexPayload) LogValue() slog.Value {
+	return slog.StringValue(fmt.Sprintf("% x", []byte(p)))
+}
+
+// tcpFrameAttrs returns the log attributes of a modbus TCP frame.
+func tcpFrameAttrs(direction string, adu []byte) []any {
+	attrs := []any{slog.String(LogKeyDirection, direction)}
+	if len(adu) > tcpHeaderSize {
+		attrs = append(attrs,
+			slog.Int(LogKeyTransactionID, int(binary.BigEndian.Uint16(adu))),
+			slog.Int(LogKeySlaveID, int(adu[6])),
+			slog.Int(LogKeyFunctionCode, int(adu[7])),
+		)
+	}
+	return append(attrs, slog.Any(LogKeyPayload, hexPayload(adu)))
+}
+
+// rtuFrameAttrs returns the log attributes of a modbus RTU frame.
+func rtuFrameAttrs(direction string, adu []byte) []any {
+	attrs := []any{slog.String(LogKeyDirection, direction)}
+	if len(adu) >= 2 {
+		attrs = append(attrs,
+			slog.Int(LogKeySlaveID, int(adu[0])),
+			slog.Int(LogKeyFunctionCode, int(adu[1])),
+		)
+	}
+	return append(attrs, slog.Any(LogKeyPayload, hexPayload(adu)))
+}
+
+// asciiFrameAttrs returns the log attributes of a modbus ASCII frame.
+func asciiFrameAttrs(direction string, adu []byte) []any {
+	attrs := []any{slog.String(LogKeyDirection, direction)}
+	var head [2]byte
+	if len(adu) >= 5 {
+		if _, err := hex.Decode(head[:], adu[1:5]); err == nil {
+			attrs = append(attrs,
+				slog.Int(LogKeySlaveID, int(head[0])),
+				slog.Int(LogKeyFunctionCode, int(head[1])),
+			)
+		}
+	}
+	return append(attrs, slog.String(LogKeyPayload, strings.TrimRight(string(adu), "\r\n")))
+}
+
+// errorAttrs returns the log attributes of an error and the action taken to
+// recover from it.
+func errorAttrs(err error, action string) []any {
+	attrs := []any{slog.Any(LogKeyError, err)}
+	if action != "" {
+		attrs = append(attrs, slog.String(LogKeyAction, action))
+	}
+	return attrs
+}
+
+// durationAttr returns the log attribute of the time elapsed since start.
+func durationAttr(start time.Time) slog.Attr {
+	return slog.Duration(LogKeyDuration, time.Since(start))
+}
@@ -0,0 +1,95 @@
+package modbus
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"log/slog"
+	"testing"
+)
+
+func TestPrintfHandler(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(NewPrintfHandler(log.New(&logs, "", 0)))
+
+	logger.With("port", "/dev/ttyUSB0").WithGroup("rtu").Warn("modbus: error reconnecting",
+		errorAttrs(errors.New("no such device"), LogActionRetry)...)
+	want := "modbus: error reconnecting port=/dev/ttyUSB0 rtu.error=no such device rtu.action=retry\n"
+	if logs.String() != want {
+		t.Fatalf("expected %q, got %q", want, logs.String())
+	}
+}
+
+func TestFrameAttrs(t *testing.T) {
+	tests := []struct {
+		name  string
+		attrs []any
+		want  map[string]any
+	}{
+		{
+			name:  "tcp",
+			attrs: tcpFrameAttrs(LogDirectionSend, []byte{0x00, 0x2A, 0x00, 0x00, 0x00, 0x06, 0x11, 0x03, 0x00, 0x6B, 0x00, 0x03}),
+			want: map[string]any{
+				"direction": "send", "transaction_id": 42.0, "slave_id": 17.0, "function_code": 3.0,
+				"payload": "00 2a 00 00 00 06 11 03 00 6b 00 03",
+			},
+		},
+		{
+			name:  "rtu",
+			attrs: rtuFrameAttrs(LogDirectionRecv, []byte{0x01, 0x83, 0x02, 0xC0, 0xF1}),
+			want: map[string]any{
+				"direction": "recv", "slave_id": 1.0, "function_code": 131.0, "payload": "01 83 02 c0 f1",
+			},
+		},
+		{
+			name:  "ascii",
+			attrs: asciiFrameAttrs(LogDirectionSend, []byte(":F7031389000A60\r\n")),
+			want: map[string]any{
+				"direction": "send", "slave_id": 247.0, "function_code": 3.0, "payload": ":F7031389000A60",
+			},
+		},
+		{
+			name:  "short",
+			attrs: rtuFrameAttrs(LogDirectionRecv, []byte{0x01}),
+			want:  map[string]any{"direction": "recv", "payload": "01"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			slog.New(slog.NewJSONHandler(&buf, nil)).Info("frame", tt.attrs...)
+
+			var record map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+				t.Fatal(err)
+			}
+			for key, want := range tt.want {
+				if got := record[key]; got != want {
+					t.Errorf("%s: expected %v, got %v", key, want, got)
+				}
+			}
+			for _, key := range []string{LogKeyTransactionID, LogKeySlaveID, LogKeyFunctionCode} {
+				if _, ok := tt.want[key]; !ok && record[key] != nil {
+					t.Errorf("unexpected attribute %s=%v", key, record[key])
+				}
+			}
+		})
+	}
+}
+
+func TestPrintfLoggerCached(t *testing.T) {
+	var buf bytes.Buffer
+	tr := &tcpTransporter{Logger: log.New(&buf, "", 0)}
+	logger := tr.logger()
+	if allocs := testing.AllocsPerRun(100, func() { tr.logger() }); allocs != 0 {
+		t.Errorf("expected no allocations, got %v", allocs)
+	}
+	if tr.logger() != logger {
+		t.Error("expected the logger to be built once")
+	}
+	tr.Logger = log.New(&buf, "other: ", 0)
+	if tr.logger() == logger {
+		t.Error("expected a new logger after changing Logger")
+	}
+}
@@ -38,6 +38,11 @@ const (
 	FuncCodeReadFIFOQueue = 24
 	// FuncCodeReadDeviceIdentification for byte wise access
 	FuncCodeReadDeviceIdentification = 43
+
+	// FuncCodeGetCommEventCounter for diagnostics (serial line only)
+	FuncCodeGetCommEventCounter = 11
+	// FuncCodeReportServerID for diagnostics (serial line only)
+	FuncCodeReportServerID = 17
 )
 
 // meiType specifies a MEI Type as defined in https://www.modbus.org/docs/Modbus_Application_Protocol_V1_1b.pdf#page=44
@@ -90,6 +95,13 @@ const (
 type Error struct {
 	FunctionCode  byte
 	ExceptionCode byte
+	// SlaveID, Address, Quantity and Transport describe the request if the
+	// exception response is returned by the client methods.
+	SlaveID  byte
+	Address  uint16
+	Quantity uint16
+	// Transport is the address of the remote device or serial port, if known.
+	Transport string
 }
 
 // Error converts known modbus exception code to error message.
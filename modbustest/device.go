@@ -0,0 +1,482 @@
+/*
+Package modbustest provides an in-process fake modbus device for tests.
+
+A Device holds an in-memory register model and records every request it
+receives. Servers make devices reachable on loopback for the handlers of the
+modbus package:
+
+	device := modbustest.NewDevice()
+	device.SetHoldingRegisters(100, 0xCAFE)
+	server := modbustest.NewTCPServer(map[byte]*modbustest.Device{1: device})
+	defer server.Close()
+
+	handler := modbus.NewTCPClientHandler(server.Addr)
+	handler.SlaveID = 1
+	results, err := modbus.NewClient(handler).ReadHoldingRegisters(ctx, 100, 1)
+	device.AssertRequestCount(t, modbus.FuncCodeReadHoldingRegisters, 1)
+*/
+package modbustest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/grid-x/modbus"
+)
+
+const (
+	addressSpace = 1 << 16
+
+	maxReadBits       = 2000
+	maxWriteBits      = 1968
+	maxReadRegisters  = 125
+	maxWriteRegisters = 123
+	maxReadWriteWrite = 121
+	maxFIFOCount      = 31
+	// Maximum PDU size without function code
+	maxPDUData = 252
+
+	meiTypeReadDeviceIdentification = 0x0E
+)
+
+// Request is a request received by a Device.
+type Request struct {
+	SlaveID      byte
+	FunctionCode byte
+	Data         []byte
+}
+
+// Address returns the starting address of the request, i.e. the first two
+// bytes of its data.
+func (r Request) Address() uint16 {
+	if len(r.Data) < 2 {
+		return 0
+	}
+	return binary.BigEndian.Uint16(r.Data)
+}
+
+func (r Request) String() string {
+	return fmt.Sprintf("slave %d, function %d, data % x", r.SlaveID, r.FunctionCode, r.Data)
+}
+
+// Device is a fake modbus device with an in-memory register model. All
+// addresses are valid. It is safe for concurrent use.
+type Device struct {
+	// Override is called for every request before the register model. If it
+	// returns a response, the response is sent instead. It can be used to
+	// inject exceptions or unsupported function codes.
+	Override func(request *modbus.ProtocolDataUnit) *modbus.ProtocolDataUnit
+
+	mu               sync.Mutex
+	coils            []bool
+	discreteInputs   []bool
+	holdingRegisters []uint16
+	inputRegisters   []uint16
+	fifoQueues       map[uint16][]uint16
+	deviceID         map[byte][]byte
+	serverID         []byte
+	requests         []Request
+}
+
+// NewDevice allocates a Device with all coils, inputs and registers zero.
+func NewDevice() *Device {
+	return &Device{
+		coils:            make([]bool, addressSpace),
+		discreteInputs:   make([]bool, addressSpace),
+		holdingRegisters: make([]uint16, addressSpace),
+		inputRegisters:   make([]uint16, addressSpace),
+		fifoQueues:       make(map[uint16][]uint16),
+		deviceID:         make(map[byte][]byte),
+	}
+}
+
+// SetCoils sets the coils starting at address.
+func (d *Device) SetCoils(address uint16, values ...bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	copy(d.coils[address:], values)
+}
+
+// Coils returns quantity coils starting at address.
+func (d *Device) Coils(address, quantity uint16) []bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]bool(nil), d.coils[address:int(address)+int(quantity)]...)
+}
+
+// SetDiscreteInputs sets the discrete inputs starting at address.
+func (d *Device) SetDiscreteInputs(address uint16, values ...bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	copy(d.discreteInputs[address:], values)
+}
+
+// DiscreteInputs returns quantity discrete inputs starting at address.
+func (d *Device) DiscreteInputs(address, quantity uint16) []bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]bool(nil), d.discreteInputs[address:int(address)+int(quantity)]...)
+}
+
+// SetHoldingRegisters sets the holding registers starting at address.
+func (d *Device) SetHoldingRegisters(address uint16, values ...uint16) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	copy(d.holdingRegisters[address:], values)
+}
+
+// HoldingRegisters returns quantity holding registers starting at address.
+func (d *Device) HoldingRegisters(address, quantity uint16) []uint16 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]uint16(nil), d.holdingRegisters[address:int(address)+int(quantity)]...)
+}
+
+// SetInputRegisters sets the input registers starting at address.
+func (d *Device) SetInputRegisters(address uint16, values ...uint16) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	copy(d.inputRegisters[address:], values)
+}
+
+// InputRegisters returns quantity input registers starting at address.
+func (d *Device) InputRegisters(address, quantity uint16) []uint16 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]uint16(nil), d.inputRegisters[address:int(address)+int(quantity)]...)
+}
+
+// SetFIFOQueue sets the content of the FIFO queue at the given pointer
+// address. Reading a FIFO queue that has not been set fails with an illegal
+// data address exception.
+func (d *Device) SetFIFOQueue(address uint16, values ...uint16) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.fifoQueues[address] = append([]uint16{}, values...)
+}
+
+// SetDeviceIdentification sets the objects returned by Read Device
+// Identification, by object id.
+func (d *Device) SetDeviceIdentification(objects map[byte]string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.deviceID = make(map[byte][]byte, len(objects))
+	for id, value := range objects {
+		d.deviceID[id] = []byte(value)
+	}
+}
+
+// SetServerID sets the data returned by Report Server ID: the server id,
+// the run indicator status and additional data. If nil, the function is not
+// supported.
+func (d *Device) SetServerID(data []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.serverID = append([]byte(nil), data...)
+}
+
+// Requests returns all requests received so far.
+func (d *Device) Requests() []Request {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]Request(nil), d.requests...)
+}
+
+// ClearRequests forgets all requests received so far.
+func (d *Device) ClearRequests() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.requests = nil
+}
+
+// RequestCount returns the number of received requests with the given
+// function code.
+func (d *Device) RequestCount(functionCode byte) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	n := 0
+	for _, r := range d.requests {
+		if r.FunctionCode == functionCode {
+			n++
+		}
+	}
+	return n
+}
+
+// AssertRequestCount fails the test unless exactly n requests with the given
+// function code have been received.
+func (d *Device) AssertRequestCount(t testing.TB, functionCode byte, n int) {
+	t.Helper()
+	if got := d.RequestCount(functionCode); got != n {
+		t.Fatalf("expected %d requests with function code %d, got %d: %v", n, functionCode, got, d.Requests())
+	}
+}
+
+// AssertRequests fails the test unless exactly the given requests have been
+// received, in order.
+func (d *Device) AssertRequests(t testing.TB, want ...Request) {
+	t.Helper()
+	got := d.Requests()
+	equal := len(got) == len(want)
+	for i := 0; equal && i < len(got); i++ {
+		equal = got[i].SlaveID == want[i].SlaveID &&
+			got[i].FunctionCode == want[i].FunctionCode &&
+			bytes.Equal(got[i].Data, want[i].Data)
+	}
+	if !equal {
+		t.Fatalf("expected requests %v, got %v", want, got)
+	}
+}
+
+// Handle records the request and returns the response of the device.
+func (d *Device) Handle(slaveID byte, request *modbus.ProtocolDataUnit) *modbus.ProtocolDataUnit {
+	d.mu.Lock()
+	d.requests = append(d.requests, Request{
+		SlaveID:      slaveID,
+		FunctionCode: request.FunctionCode,
+		Data:         append([]byte(nil), request.Data...),
+	})
+	override := d.Override
+	d.mu.Unlock()
+
+	if override != nil {
+		if response := override(request); response != nil {
+			return response
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, code := d.handle(request)
+	if code != 0 {
+		return Exception(request.FunctionCode, code)
+	}
+	return &modbus.ProtocolDataUnit{FunctionCode: request.FunctionCode, Data: data}
+}
+
+// Exception returns the exception response for a function code.
+func Exception(functionCode, exceptionCode byte) *modbus.ProtocolDataUnit {
+	return &modbus.ProtocolDataUnit{FunctionCode: functionCode | 0x80, Data: []byte{exceptionCode}}
+}
+
+// handle returns the response data or an exception code. Caller must hold the
+// mutex.
+func (d *Device) handle(request *modbus.ProtocolDataUnit) ([]byte, byte) {
+	data := request.Data
+	switch request.FunctionCode {
+	case modbus.FuncCodeReadCoils:
+		return readBits(d.coils, data)
+	case modbus.FuncCodeReadDiscreteInputs:
+		return readBits(d.discreteInputs, data)
+	case modbus.FuncCodeReadHoldingRegisters:
+		return readRegisters(d.holdingRegisters, data)
+	case modbus.FuncCodeReadInputRegisters:
+		return readRegisters(d.inputRegisters, data)
+	case modbus.FuncCodeWriteSingleCoil:
+		if len(data) != 4 {
+			return nil, modbus.ExceptionCodeIllegalDataValue
+		}
+		address, value := binary.BigEndian.Uint16(data), binary.BigEndian.Uint16(data[2:])
+		if value != 0xFF00 && value != 0x0000 {
+			return nil, modbus.ExceptionCodeIllegalDataValue
+		}
+		d.coils[address] = value == 0xFF00
+		return data, 0
+	case modbus.FuncCodeWriteSingleRegister:
+		if len(data) != 4 {
+			return nil, modbus.ExceptionCodeIllegalDataValue
+		}
+		d.holdingRegisters[binary.BigEndian.Uint16(data)] = binary.BigEndian.Uint16(data[2:])
+		return data, 0
+	case modbus.FuncCodeWriteMultipleCoils:
+		return writeBits(d.coils, data)
+	case modbus.FuncCodeWriteMultipleRegisters:
+		return writeRegisters(d.holdingRegisters, data, maxWriteRegisters)
+	case modbus.FuncCodeMaskWriteRegister:
+		if len(data) != 6 {
+			return nil, modbus.ExceptionCodeIllegalDataValue
+		}
+		address := binary.BigEndian.Uint16(data)
+		andMask, orMask := binary.BigEndian.Uint16(data[2:]), binary.BigEndian.Uint16(data[4:])
+		d.holdingRegisters[address] = (d.holdingRegisters[address] & andMask) | (orMask &^ andMask)
+		return data, 0
+	case modbus.FuncCodeReadWriteMultipleRegisters:
+		if len(data) < 9 {
+			return nil, modbus.ExceptionCodeIllegalDataValue
+		}
+		// the write operation is performed before the read
+		if _, code := writeRegisters(d.holdingRegisters, data[4:], maxReadWriteWrite); code != 0 {
+			return nil, code
+		}
+		return readRegisters(d.holdingRegisters, data[:4])
+	case modbus.FuncCodeReadFIFOQueue:
+		if len(data) != 2 {
+			return nil, modbus.ExceptionCodeIllegalDataValue
+		}
+		queue, ok := d.fifoQueues[binary.BigEndian.Uint16(data)]
+		if !ok {
+			return nil, modbus.ExceptionCodeIllegalDataAddress
+		}
+		if len(queue) > maxFIFOCount {
+			return nil, modbus.ExceptionCodeIllegalDataValue
+		}
+		response := make([]byte, 4+2*len(queue))
+		binary.BigEndian.PutUint16(response, uint16(2+2*len(queue)))
+		binary.BigEndian.PutUint16(response[2:], uint16(len(queue)))
+		for i, v := range queue {
+			binary.BigEndian.PutUint16(response[4+2*i:], v)
+		}
+		return response, 0
+	case modbus.FuncCodeReadDeviceIdentification:
+		return d.readDeviceIdentification(data)
+	case modbus.FuncCodeReportServerID:
+		if d.serverID == nil {
+			return nil, modbus.ExceptionCodeIllegalFunction
+		}
+		return append([]byte{byte(len(d.serverID))}, d.serverID...), 0
+	default:
+		return nil, modbus.ExceptionCodeIllegalFunction
+	}
+}
+
+// quantityRange validates address and quantity of a request.
+func quantityRange(data []byte, max int) (address, quantity int, code byte) {
+	if len(data) < 4 {
+		return 0, 0, modbus.ExceptionCodeIllegalDataValue
+	}
+	address, quantity = int(binary.BigEndian.Uint16(data)), int(binary.BigEndian.Uint16(data[2:]))
+	if quantity < 1 || quantity > max {
+		return 0, 0, modbus.ExceptionCodeIllegalDataValue
+	}
+	if address+quantity > addressSpace {
+		return 0, 0, modbus.ExceptionCodeIllegalDataAddress
+	}
+	return address, quantity, 0
+}
+
+func readBits(bits []bool, data []byte) ([]byte, byte) {
+	address, quantity, code := quantityRange(data, maxReadBits)
+	if code != 0 {
+		return nil, code
+	}
+	response := make([]byte, 1+(quantity+7)/8)
+	response[0] = byte(len(response) - 1)
+	for i := 0; i < quantity; i++ {
+		if bits[address+i] {
+			response[1+i/8] |= 1 << (i % 8)
+		}
+	}
+	return response, 0
+}
+
+func readRegisters(registers []uint16, data []byte) ([]byte, byte) {
+	address, quantity, code := quantityRange(data, maxReadRegisters)
+	if code != 0 {
+		return nil, code
+	}
+	response := make([]byte, 1+2*quantity)
+	response[0] = byte(2 * quantity)
+	for i := 0; i < quantity; i++ {
+		binary.BigEndian.PutUint16(response[1+2*i:], registers[address+i])
+	}
+	return response, 0
+}
+
+func writeBits(bits []bool, data []byte) ([]byte, byte) {
+	address, quantity, code := quantityRange(data, maxWriteBits)
+	if code != 0 {
+		return nil, code
+	}
+	if len(data) < 5 || int(data[4]) != (quantity+7)/8 || len(data) != 5+int(data[4]) {
+		return nil, modbus.ExceptionCodeIllegalDataValue
+	}
+	for i := 0; i < quantity; i++ {
+		bits[address+i] = data[5+i/8]&(1<<(i%8)) != 0
+	}
+	return data[:4], 0
+}
+
+func writeRegisters(registers []uint16, data []byte, max int) ([]byte, byte) {
+	address, quantity, code := quantityRange(data, max)
+	if code != 0 {
+		return nil, code
+	}
+	if len(data) < 5 || int(data[4]) != 2*quantity || len(data) != 5+int(data[4]) {
+		return nil, modbus.ExceptionCodeIllegalDataValue
+	}
+	for i := 0; i < quantity; i++ {
+		registers[address+i] = binary.BigEndian.Uint16(data[5+2*i:])
+	}
+	return data[:4], 0
+}
+
+// readDeviceIdentification answers a Read Device Identification request.
+// Caller must hold the mutex.
+func (d *Device) readDeviceIdentification(data []byte) ([]byte, byte) {
+	if len(data) != 3 || data[0] != meiTypeReadDeviceIdentification {
+		return nil, modbus.ExceptionCodeIllegalDataValue
+	}
+	code, objectID := modbus.ReadDeviceIDCode(data[1]), data[2]
+
+	var last int
+	switch code {
+	case modbus.ReadDeviceIDCodeBasic:
+		last = 0x02
+	case modbus.ReadDeviceIDCodeRegular:
+		last = 0x7F
+	case modbus.ReadDeviceIDCodeExtended:
+		last = 0xFF
+	case modbus.ReadDeviceIDCodeSpecific:
+		last = int(objectID)
+	default:
+		return nil, modbus.ExceptionCodeIllegalDataValue
+	}
+	if _, ok := d.deviceID[objectID]; !ok {
+		if code == modbus.ReadDeviceIDCodeSpecific || len(d.deviceID) == 0 {
+			return nil, modbus.ExceptionCodeIllegalDataAddress
+		}
+		// restart at the first object of the category
+		objectID = 0
+	}
+
+	ids := make([]int, 0, len(d.deviceID))
+	for id := range d.deviceID {
+		if id >= objectID && int(id) <= last {
+			ids = append(ids, int(id))
+		}
+	}
+	sort.Ints(ids)
+
+	response := []byte{meiTypeReadDeviceIdentification, byte(code), d.conformityLevel(), 0x00, 0x00, 0x00}
+	for _, id := range ids {
+		value := d.deviceID[byte(id)]
+		if len(response)+2+len(value) > maxPDUData {
+			// more follows
+			response[3], response[4] = 0xFF, byte(id)
+			break
+		}
+		response = append(response, byte(id), byte(len(value)))
+		response = append(response, value...)
+		response[5]++
+	}
+	return response, 0
+}
+
+// conformityLevel returns the conformity level of the device identification
+// objects, always supporting individual access.
+func (d *Device) conformityLevel() byte {
+	level := byte(0x01)
+	for id := range d.deviceID {
+		switch {
+		case id >= 0x80:
+			level = 0x03
+		case id >= 0x03 && level < 0x02:
+			level = 0x02
+		}
+	}
+	return 0x80 | level
+}
@@ -0,0 +1,116 @@
+package modbustest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/grid-x/modbus"
+)
+
+func TestDeviceHandle(t *testing.T) {
+	device := NewDevice()
+	device.SetHoldingRegisters(4, 0x0012)
+	device.SetFIFOQueue(0x04DE, 0x01B8, 0x1284)
+	device.SetDeviceIdentification(map[byte]string{0: "grid-x", 1: "fake", 2: "1.0", 0x80: "private"})
+	device.SetServerID([]byte{0x2A, 0xFF, 'f', 'a', 'k', 'e'})
+
+	tests := []struct {
+		name     string
+		request  *modbus.ProtocolDataUnit
+		response *modbus.ProtocolDataUnit
+	}{
+		{
+			name:     "mask write register",
+			request:  &modbus.ProtocolDataUnit{FunctionCode: modbus.FuncCodeMaskWriteRegister, Data: []byte{0, 4, 0, 0xF2, 0, 0x25}},
+			response: &modbus.ProtocolDataUnit{FunctionCode: modbus.FuncCodeMaskWriteRegister, Data: []byte{0, 4, 0, 0xF2, 0, 0x25}},
+		},
+		{
+			name:     "read masked register",
+			request:  &modbus.ProtocolDataUnit{FunctionCode: modbus.FuncCodeReadHoldingRegisters, Data: []byte{0, 4, 0, 1}},
+			response: &modbus.ProtocolDataUnit{FunctionCode: modbus.FuncCodeReadHoldingRegisters, Data: []byte{2, 0, 0x17}},
+		},
+		{
+			name:     "read write multiple registers",
+			request:  &modbus.ProtocolDataUnit{FunctionCode: modbus.FuncCodeReadWriteMultipleRegisters, Data: []byte{0, 9, 0, 2, 0, 10, 0, 1, 2, 0xAB, 0xCD}},
+			response: &modbus.ProtocolDataUnit{FunctionCode: modbus.FuncCodeReadWriteMultipleRegisters, Data: []byte{4, 0, 0, 0xAB, 0xCD}},
+		},
+		{
+			name:     "read fifo queue",
+			request:  &modbus.ProtocolDataUnit{FunctionCode: modbus.FuncCodeReadFIFOQueue, Data: []byte{0x04, 0xDE}},
+			response: &modbus.ProtocolDataUnit{FunctionCode: modbus.FuncCodeReadFIFOQueue, Data: []byte{0, 6, 0, 2, 0x01, 0xB8, 0x12, 0x84}},
+		},
+		{
+			name:     "read unknown fifo queue",
+			request:  &modbus.ProtocolDataUnit{FunctionCode: modbus.FuncCodeReadFIFOQueue, Data: []byte{0, 1}},
+			response: Exception(modbus.FuncCodeReadFIFOQueue, modbus.ExceptionCodeIllegalDataAddress),
+		},
+		{
+			name:     "read too many coils",
+			request:  &modbus.ProtocolDataUnit{FunctionCode: modbus.FuncCodeReadCoils, Data: []byte{0, 0, 0x07, 0xD1}},
+			response: Exception(modbus.FuncCodeReadCoils, modbus.ExceptionCodeIllegalDataValue),
+		},
+		{
+			name:     "read beyond address space",
+			request:  &modbus.ProtocolDataUnit{FunctionCode: modbus.FuncCodeReadInputRegisters, Data: []byte{0xFF, 0xFF, 0, 2}},
+			response: Exception(modbus.FuncCodeReadInputRegisters, modbus.ExceptionCodeIllegalDataAddress),
+		},
+		{
+			name:     "illegal function",
+			request:  &modbus.ProtocolDataUnit{FunctionCode: 0x41},
+			response: Exception(0x41, modbus.ExceptionCodeIllegalFunction),
+		},
+		{
+			name:    "read basic device identification",
+			request: &modbus.ProtocolDataUnit{FunctionCode: modbus.FuncCodeReadDeviceIdentification, Data: []byte{0x0E, 1, 0}},
+			response: &modbus.ProtocolDataUnit{FunctionCode: modbus.FuncCodeReadDeviceIdentification, Data: []byte{
+				0x0E, 1, 0x83, 0, 0, 3,
+				0, 6, 'g', 'r', 'i', 'd', '-', 'x',
+				1, 4, 'f', 'a', 'k', 'e',
+				2, 3, '1', '.', '0',
+			}},
+		},
+		{
+			name:    "read specific device identification",
+			request: &modbus.ProtocolDataUnit{FunctionCode: modbus.FuncCodeReadDeviceIdentification, Data: []byte{0x0E, 4, 0x80}},
+			response: &modbus.ProtocolDataUnit{FunctionCode: modbus.FuncCodeReadDeviceIdentification, Data: []byte{
+				0x0E, 4, 0x83, 0, 0, 1,
+				0x80, 7, 'p', 'r', 'i', 'v', 'a', 't', 'e',
+			}},
+		},
+		{
+			name:     "report server id",
+			request:  &modbus.ProtocolDataUnit{FunctionCode: modbus.FuncCodeReportServerID},
+			response: &modbus.ProtocolDataUnit{FunctionCode: modbus.FuncCodeReportServerID, Data: []byte{6, 0x2A, 0xFF, 'f', 'a', 'k', 'e'}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := device.Handle(1, tt.request); !reflect.DeepEqual(got, tt.response) {
+				t.Fatalf("expected %+v, got %+v", tt.response, got)
+			}
+		})
+	}
+	device.AssertRequestCount(t, modbus.FuncCodeReadFIFOQueue, 2)
+}
+
+func TestDeviceOverride(t *testing.T) {
+	device := NewDevice()
+	device.Override = func(request *modbus.ProtocolDataUnit) *modbus.ProtocolDataUnit {
+		if request.FunctionCode == modbus.FuncCodeWriteSingleRegister {
+			return Exception(request.FunctionCode, modbus.ExceptionCodeServerDeviceBusy)
+		}
+		return nil
+	}
+
+	busy := device.Handle(1, &modbus.ProtocolDataUnit{FunctionCode: modbus.FuncCodeWriteSingleRegister, Data: []byte{0, 1, 0, 2}})
+	if want := Exception(modbus.FuncCodeWriteSingleRegister, modbus.ExceptionCodeServerDeviceBusy); !reflect.DeepEqual(busy, want) {
+		t.Fatalf("expected %+v, got %+v", want, busy)
+	}
+	if got := device.HoldingRegisters(1, 1); got[0] != 0 {
+		t.Fatalf("expected register to be unchanged, got %v", got)
+	}
+	device.Handle(1, &modbus.ProtocolDataUnit{FunctionCode: modbus.FuncCodeWriteSingleCoil, Data: []byte{0, 1, 0xFF, 0}})
+	if got := device.Coils(1, 1); !got[0] {
+		t.Fatal("expected coil to be set")
+	}
+}
@@ -0,0 +1,399 @@
+package modbustest
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/grid-x/modbus"
+)
+
+// Fault is a fault injected into a response frame.
+type Fault int
+
+const (
+	// FaultNone delivers the frame unchanged.
+	FaultNone Fault = iota
+	// FaultDrop discards the frame.
+	FaultDrop
+	// FaultDelay delivers the frame after the delay of the wrapper.
+	FaultDelay
+	// FaultTruncate delivers only the first half of the frame.
+	FaultTruncate
+	// FaultDuplicate delivers the frame twice.
+	FaultDuplicate
+	// FaultCorruptChecksum breaks the CRC of RTU or the LRC of ASCII frames.
+	// TCP frames have no checksum, their last byte is flipped instead.
+	FaultCorruptChecksum
+	// FaultTransactionID increments the transaction id of TCP frames. It does
+	// not change RTU and ASCII frames.
+	FaultTransactionID
+	// FaultUnitID increments the unit or slave id, keeping the checksum valid.
+	FaultUnitID
+	// FaultSplit delivers the frame in two halves, the second one after the
+	// delay of the wrapper.
+	FaultSplit
+)
+
+func (f Fault) String() string {
+	switch f {
+	case FaultNone:
+		return "none"
+	case FaultDrop:
+		return "drop"
+	case FaultDelay:
+		return "delay"
+	case FaultTruncate:
+		return "truncate"
+	case FaultDuplicate:
+		return "duplicate"
+	case FaultCorruptChecksum:
+		return "corrupt checksum"
+	case FaultTransactionID:
+		return "transaction id"
+	case FaultUnitID:
+		return "unit id"
+	case FaultSplit:
+		return "split"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(f))
+	}
+}
+
+// Schedule decides which fault is injected into the next response frame.
+// Schedules are not safe for concurrent use, each wrapper needs its own.
+type Schedule interface {
+	Next() Fault
+}
+
+type sequence struct {
+	faults []Fault
+}
+
+// Sequence returns a Schedule injecting the given faults into the first
+// frames, in order, and no faults afterwards.
+func Sequence(faults ...Fault) Schedule {
+	return &sequence{faults: faults}
+}
+
+func (s *sequence) Next() Fault {
+	if len(s.faults) == 0 {
+		return FaultNone
+	}
+	f := s.faults[0]
+	s.faults = s.faults[1:]
+	return f
+}
+
+type randomSchedule struct {
+	rand   *rand.Rand
+	rate   float64
+	faults []Fault
+}
+
+// RandomSchedule returns a Schedule injecting one of the given faults, chosen
+// uniformly, into a frame with probability rate. The same seed yields the same
+// schedule.
+func RandomSchedule(seed int64, rate float64, faults ...Fault) Schedule {
+	return &randomSchedule{rand: rand.New(rand.NewSource(seed)), rate: rate, faults: faults}
+}
+
+func (s *randomSchedule) Next() Fault {
+	if len(s.faults) == 0 || s.rand.Float64() >= s.rate {
+		return FaultNone
+	}
+	return s.faults[s.rand.Intn(len(s.faults))]
+}
+
+// chunk is a part of a response that becomes readable at a point in time.
+type chunk struct {
+	data []byte
+	at   time.Time
+}
+
+// faultReader splits a response stream into frames and injects faults.
+type faultReader struct {
+	transport modbus.TransportType
+	schedule  Schedule
+	delay     time.Duration
+	r         *bufio.Reader
+
+	// readMu serializes Read, mu guards the fields below
+	readMu   sync.Mutex
+	mu       sync.Mutex
+	pending  []chunk
+	injected []Fault
+	deadline time.Time
+}
+
+func newFaultReader(r io.Reader, transport modbus.TransportType, schedule Schedule) *faultReader {
+	return &faultReader{
+		transport: transport,
+		schedule:  schedule,
+		delay:     100 * time.Millisecond,
+		r:         bufio.NewReader(r),
+	}
+}
+
+func (fr *faultReader) Read(p []byte) (int, error) {
+	fr.readMu.Lock()
+	defer fr.readMu.Unlock()
+
+	fr.mu.Lock()
+	for len(fr.pending) == 0 {
+		fr.mu.Unlock()
+		frame, err := readResponseFrame(fr.r, fr.transport)
+		fr.mu.Lock()
+		if len(frame) > 0 {
+			fr.inject(frame)
+		}
+		if err != nil && len(fr.pending) == 0 {
+			fr.mu.Unlock()
+			return 0, err
+		}
+	}
+	c, deadline := fr.pending[0], fr.deadline
+	fr.mu.Unlock()
+
+	if wait := time.Until(c.at); wait > 0 {
+		if !deadline.IsZero() && deadline.Before(c.at) {
+			time.Sleep(time.Until(deadline))
+			return 0, os.ErrDeadlineExceeded
+		}
+		time.Sleep(wait)
+	}
+
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	n := copy(p, c.data)
+	if n == len(c.data) {
+		fr.pending = fr.pending[1:]
+	} else {
+		fr.pending[0].data = c.data[n:]
+	}
+	return n, nil
+}
+
+// inject queues the frame with the next fault of the schedule. Caller must
+// hold the mutex.
+func (fr *faultReader) inject(frame []byte) {
+	now := time.Now()
+	fault := FaultNone
+	if fr.schedule != nil {
+		fault = fr.schedule.Next()
+	}
+	fr.injected = append(fr.injected, fault)
+
+	switch fault {
+	case FaultDrop:
+	case FaultDelay:
+		fr.pending = append(fr.pending, chunk{data: frame, at: now.Add(fr.delay)})
+	case FaultTruncate:
+		fr.pending = append(fr.pending, chunk{data: frame[:(len(frame)+1)/2], at: now})
+	case FaultDuplicate:
+		fr.pending = append(fr.pending, chunk{data: frame, at: now}, chunk{data: append([]byte(nil), frame...), at: now})
+	case FaultSplit:
+		half := len(frame) / 2
+		fr.pending = append(fr.pending, chunk{data: frame[:half], at: now}, chunk{data: frame[half:], at: now.Add(fr.delay)})
+	case FaultCorruptChecksum:
+		fr.pending = append(fr.pending, chunk{data: corruptChecksum(frame, fr.transport), at: now})
+	case FaultTransactionID:
+		if fr.transport == modbus.TransportTCP && len(frame) >= 2 {
+			binary.BigEndian.PutUint16(frame, binary.BigEndian.Uint16(frame)+1)
+		}
+		fr.pending = append(fr.pending, chunk{data: frame, at: now})
+	case FaultUnitID:
+		fr.pending = append(fr.pending, chunk{data: changeUnitID(frame, fr.transport), at: now})
+	default:
+		fr.pending = append(fr.pending, chunk{data: frame, at: now})
+	}
+}
+
+func (fr *faultReader) setDeadline(t time.Time) {
+	fr.mu.Lock()
+	fr.deadline = t
+	fr.mu.Unlock()
+}
+
+func (fr *faultReader) faults() []Fault {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	return append([]Fault(nil), fr.injected...)
+}
+
+// readResponseFrame reads the next response frame. On errors the bytes read
+// so far are returned with the error.
+func readResponseFrame(r *bufio.Reader, transport modbus.TransportType) ([]byte, error) {
+	switch transport {
+	case modbus.TransportTCP:
+		header, err := r.Peek(tcpHeaderSize)
+		if err != nil {
+			return readBuffered(r, err)
+		}
+		return readN(r, tcpHeaderSize-1+int(binary.BigEndian.Uint16(header[4:])))
+	case modbus.TransportASCII:
+		return r.ReadBytes('\n')
+	default:
+		length, err := rtuFrameLength(r, true)
+		if err != nil {
+			return readBuffered(r, err)
+		}
+		if length < 0 {
+			// unknown function code, take what has been received
+			length = r.Buffered()
+		}
+		return readN(r, length)
+	}
+}
+
+func readN(r *bufio.Reader, n int) ([]byte, error) {
+	frame := make([]byte, n)
+	n, err := io.ReadFull(r, frame)
+	return frame[:n], err
+}
+
+func readBuffered(r *bufio.Reader, err error) ([]byte, error) {
+	frame, _ := readN(r, r.Buffered())
+	return frame, err
+}
+
+func corruptChecksum(frame []byte, transport modbus.TransportType) []byte {
+	switch {
+	case transport == modbus.TransportASCII && len(frame) >= 4:
+		// replace the last LRC digit with another hex digit
+		i := len(frame) - 3
+		if frame[i] == '0' {
+			frame[i] = '1'
+		} else {
+			frame[i] = '0'
+		}
+	case len(frame) > 0:
+		frame[len(frame)-1] ^= 0xFF
+	}
+	return frame
+}
+
+func changeUnitID(frame []byte, transport modbus.TransportType) []byte {
+	var packager modbus.Packager
+	switch transport {
+	case modbus.TransportTCP:
+		if len(frame) > 6 {
+			frame[6]++
+		}
+		return frame
+	case modbus.TransportASCII:
+		packager = modbus.NewASCIIClientHandler("")
+	default:
+		packager = modbus.NewRTUClientHandler("")
+	}
+	c := codecFor(transport, packager)
+	slaveID, pdu, err := c.decode(frame)
+	if err != nil {
+		return frame
+	}
+	reencoded, err := c.encode(frame, slaveID+1, pdu)
+	if err != nil {
+		return frame
+	}
+	return reencoded
+}
+
+func codecFor(transport modbus.TransportType, packager modbus.Packager) codec {
+	if transport == modbus.TransportASCII {
+		return &asciiCodec{packager: packager}
+	}
+	return &rtuCodec{packager: packager}
+}
+
+// FaultConn is a net.Conn injecting faults into the frames read from it, e.g.
+// returned by a modbus.DialFunc passed to modbus.WithDialer:
+//
+//	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+//		conn, err := (&net.Dialer{}).DialContext(ctx, network, addr)
+//		if err != nil {
+//			return nil, err
+//		}
+//		return modbustest.NewFaultConn(conn, modbus.TransportTCP, modbustest.RandomSchedule(1, 0.2, modbustest.FaultDrop)), nil
+//	}
+//
+// Read deadlines are honored for delayed frames.
+type FaultConn struct {
+	net.Conn
+	reader *faultReader
+}
+
+// NewFaultConn wraps conn carrying frames of the given transport.
+func NewFaultConn(conn net.Conn, transport modbus.TransportType, schedule Schedule) *FaultConn {
+	return &FaultConn{Conn: conn, reader: newFaultReader(conn, transport, schedule)}
+}
+
+// SetDelay sets the delay of FaultDelay and FaultSplit, 100ms by default.
+func (c *FaultConn) SetDelay(delay time.Duration) {
+	c.reader.mu.Lock()
+	c.reader.delay = delay
+	c.reader.mu.Unlock()
+}
+
+// Faults returns the faults injected so far, one per frame read.
+func (c *FaultConn) Faults() []Fault {
+	return c.reader.faults()
+}
+
+func (c *FaultConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+func (c *FaultConn) SetDeadline(t time.Time) error {
+	c.reader.setDeadline(t)
+	return c.Conn.SetDeadline(t)
+}
+
+func (c *FaultConn) SetReadDeadline(t time.Time) error {
+	c.reader.setDeadline(t)
+	return c.Conn.SetReadDeadline(t)
+}
+
+// FaultPort is an io.ReadWriteCloser injecting faults into the frames read
+// from a serial port, e.g. returned by a modbus.SerialOpenFunc:
+//
+//	handler := modbus.NewRTUClientHandler("/dev/ttyUSB0")
+//	handler.Open = func(c *serial.Config) (io.ReadWriteCloser, error) {
+//		port, err := serial.Open(c)
+//		if err != nil {
+//			return nil, err
+//		}
+//		return modbustest.NewFaultPort(port, modbus.TransportRTU, modbustest.Sequence(modbustest.FaultCorruptChecksum)), nil
+//	}
+//
+// Delayed frames block the reader for the full delay.
+type FaultPort struct {
+	io.ReadWriteCloser
+	reader *faultReader
+}
+
+// NewFaultPort wraps port carrying frames of the given transport.
+func NewFaultPort(port io.ReadWriteCloser, transport modbus.TransportType, schedule Schedule) *FaultPort {
+	return &FaultPort{ReadWriteCloser: port, reader: newFaultReader(port, transport, schedule)}
+}
+
+// SetDelay sets the delay of FaultDelay and FaultSplit, 100ms by default.
+func (p *FaultPort) SetDelay(delay time.Duration) {
+	p.reader.mu.Lock()
+	p.reader.delay = delay
+	p.reader.mu.Unlock()
+}
+
+// Faults returns the faults injected so far, one per frame read.
+func (p *FaultPort) Faults() []Fault {
+	return p.reader.faults()
+}
+
+func (p *FaultPort) Read(b []byte) (int, error) {
+	return p.reader.Read(b)
+}
@@ -0,0 +1,151 @@
+package modbustest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/grid-x/modbus"
+	"github.com/grid-x/serial"
+)
+
+func faultDialer(transport modbus.TransportType, schedule Schedule, conns chan<- *FaultConn) modbus.DialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := (&net.Dialer{}).DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		fc := NewFaultConn(conn, transport, schedule)
+		fc.SetDelay(50 * time.Millisecond)
+		conns <- fc
+		return fc, nil
+	}
+}
+
+func TestFaultConnTCPRecovery(t *testing.T) {
+	device := NewDevice()
+	device.SetHoldingRegisters(0, 7)
+	server := NewTCPServer(map[byte]*Device{1: device})
+	defer server.Close()
+
+	conns := make(chan *FaultConn, 1)
+	schedule := Sequence(FaultTransactionID, FaultDelay, FaultSplit, FaultDrop)
+	handler := modbus.NewTCPClientHandler(server.Addr, modbus.WithDialer(faultDialer(modbus.TransportTCP, schedule, conns)))
+	handler.SlaveID = 1
+	handler.Timeout = 200 * time.Millisecond
+	handler.ProtocolRecoveryTimeout = time.Second
+	client := modbus.NewClient(handler)
+	defer handler.Close()
+	ctx := context.Background()
+
+	// the response with the wrong transaction id is skipped and the request repeated
+	results, err := client.ReadHoldingRegisters(ctx, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(results, []byte{0, 7}) {
+		t.Fatalf("unexpected results % x", results)
+	}
+	device.AssertRequestCount(t, modbus.FuncCodeReadHoldingRegisters, 2)
+
+	// delayed and split responses arrive within the timeout
+	if _, err = client.ReadHoldingRegisters(ctx, 0, 1); err != nil {
+		t.Fatal(err)
+	}
+	// the dropped response times out
+	var netErr net.Error
+	if _, err = client.ReadHoldingRegisters(ctx, 0, 1); !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+
+	conn := <-conns
+	want := []Fault{FaultTransactionID, FaultDelay, FaultSplit, FaultDrop}
+	if got := conn.Faults(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected faults %v, got %v", want, got)
+	}
+}
+
+func TestFaultConnRTUOverTCP(t *testing.T) {
+	server := NewRTUOverTCPServer(map[byte]*Device{1: NewDevice(), 2: NewDevice()})
+	defer server.Close()
+
+	conns := make(chan *FaultConn, 1)
+	schedule := Sequence(FaultCorruptChecksum, FaultUnitID)
+	handler := modbus.NewRTUOverTCPClientHandler(server.Addr)
+	handler.Dial = faultDialer(modbus.TransportRTU, schedule, conns)
+	handler.SlaveID = 1
+	client := modbus.NewClient(handler)
+	defer handler.Close()
+	ctx := context.Background()
+
+	if _, err := client.ReadCoils(ctx, 0, 8); err == nil {
+		t.Fatal("expected checksum error")
+	}
+	if _, err := client.ReadCoils(ctx, 0, 8); err == nil {
+		t.Fatal("expected slave id mismatch")
+	}
+	if _, err := client.ReadCoils(ctx, 0, 8); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRandomScheduleIsDeterministic(t *testing.T) {
+	faults := []Fault{FaultDrop, FaultDelay, FaultCorruptChecksum}
+	first, second := RandomSchedule(42, 0.5, faults...), RandomSchedule(42, 0.5, faults...)
+	injected := 0
+	for i := 0; i < 100; i++ {
+		f := first.Next()
+		if g := second.Next(); f != g {
+			t.Fatalf("schedules differ at %d: %v != %v", i, f, g)
+		}
+		if f != FaultNone {
+			injected++
+		}
+	}
+	if injected == 0 || injected == 100 {
+		t.Fatalf("expected some faults, got %d of 100", injected)
+	}
+}
+
+func TestFaultPortSerialReconnect(t *testing.T) {
+	device := NewDevice()
+	server := NewRTUOverTCPServer(map[byte]*Device{1: device})
+	defer server.Close()
+
+	opened, schedule := 0, Sequence(FaultCorruptChecksum)
+	handler := modbus.NewRTUClientHandler("/dev/fake")
+	handler.BaudRate = 115200
+	handler.SlaveID = 1
+	handler.Timeout = time.Second
+	handler.LinkRecoveryTimeout = time.Second
+	handler.Open = func(*serial.Config) (io.ReadWriteCloser, error) {
+		opened++
+		conn, err := net.Dial("tcp", server.Addr)
+		if err != nil {
+			return nil, err
+		}
+		return NewFaultPort(conn, modbus.TransportRTU, schedule), nil
+	}
+	client := modbus.NewClient(handler)
+	defer handler.Close()
+	ctx := context.Background()
+
+	if _, err := client.WriteSingleRegister(ctx, 3, 0xABCD); err == nil {
+		t.Fatal("expected checksum error")
+	}
+	// a lost link is recovered by reopening the port
+	server.CloseConnections()
+	if _, err := client.WriteSingleRegister(ctx, 3, 0x1234); err != nil {
+		t.Fatal(err)
+	}
+	if opened != 2 {
+		t.Fatalf("expected port to be opened twice, got %d", opened)
+	}
+	if got := device.HoldingRegisters(3, 1); got[0] != 0x1234 {
+		t.Fatalf("unexpected register value %x", got[0])
+	}
+}
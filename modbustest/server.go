@@ -0,0 +1,480 @@
+package modbustest
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/grid-x/modbus"
+)
+
+const (
+	tcpHeaderSize = 7
+	tcpMaxLength  = 260
+	rtuMaxLength  = 256
+	udpMaxLength  = 1024
+)
+
+// Server serves devices on a loopback address, like httptest.Server does for
+// http handlers.
+type Server struct {
+	// Addr is the address the server listens on, e.g. "127.0.0.1:50200".
+	Addr string
+
+	devices  map[byte]*Device
+	newCodec func() codec
+
+	listener   net.Listener
+	packetConn net.PacketConn
+
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// codec reads, decodes and encodes the frames of one transport.
+type codec interface {
+	// readFrame reads the next request ADU of a stream.
+	readFrame(r *bufio.Reader) ([]byte, error)
+	// decode returns the slave id and PDU of a request ADU.
+	decode(adu []byte) (slaveID byte, pdu *modbus.ProtocolDataUnit, err error)
+	// encode returns the response ADU answering the request ADU.
+	encode(aduRequest []byte, slaveID byte, pdu *modbus.ProtocolDataUnit) ([]byte, error)
+	// gateway reports whether requests to unknown slaves are answered with
+	// an exception instead of silence.
+	gateway() bool
+}
+
+// NewTCPServer starts a modbus TCP server for devices by unit id. Requests to
+// unknown unit ids are answered with a gateway target device failed to respond
+// exception.
+func NewTCPServer(devices map[byte]*Device) *Server {
+	return newStreamServer(devices, func() codec { return &tcpCodec{packager: modbus.NewTCPClientHandler("")} })
+}
+
+// NewRTUOverTCPServer starts a modbus RTU over TCP server for devices by slave
+// id. Requests to unknown slave ids are not answered.
+func NewRTUOverTCPServer(devices map[byte]*Device) *Server {
+	return newStreamServer(devices, func() codec { return &rtuCodec{packager: modbus.NewRTUClientHandler("")} })
+}
+
+// NewASCIIOverTCPServer starts a modbus ASCII over TCP server for devices by
+// slave id. Requests to unknown slave ids are not answered.
+func NewASCIIOverTCPServer(devices map[byte]*Device) *Server {
+	return newStreamServer(devices, func() codec { return &asciiCodec{packager: modbus.NewASCIIClientHandler("")} })
+}
+
+// NewRTUOverUDPServer starts a modbus RTU over UDP server for devices by slave
+// id. Requests to unknown slave ids are not answered.
+func NewRTUOverUDPServer(devices map[byte]*Device) *Server {
+	return newPacketServer(devices, func() codec { return &rtuCodec{packager: modbus.NewRTUClientHandler("")} })
+}
+
+// NewTCPOverUDPServer starts a modbus TCP over UDP server for devices by unit
+// id. Requests to unknown unit ids are answered with a gateway target device
+// failed to respond exception.
+func NewTCPOverUDPServer(devices map[byte]*Device) *Server {
+	return newPacketServer(devices, func() codec { return &tcpCodec{packager: modbus.NewTCPClientHandler("")} })
+}
+
+// NewASCIIOverUDPServer starts a modbus ASCII over UDP server for devices by
+// slave id. Requests to unknown slave ids are not answered.
+func NewASCIIOverUDPServer(devices map[byte]*Device) *Server {
+	return newPacketServer(devices, func() codec { return &asciiCodec{packager: modbus.NewASCIIClientHandler("")} })
+}
+
+func newStreamServer(devices map[byte]*Device, newCodec func() codec) *Server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("modbustest: failed to listen: %v", err))
+	}
+	s := &Server{
+		Addr:     ln.Addr().String(),
+		devices:  devices,
+		newCodec: newCodec,
+		listener: ln,
+		conns:    make(map[net.Conn]struct{}),
+	}
+	s.wg.Add(1)
+	go s.accept()
+	return s
+}
+
+func newPacketServer(devices map[byte]*Device, newCodec func() codec) *Server {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("modbustest: failed to listen: %v", err))
+	}
+	s := &Server{
+		Addr:       conn.LocalAddr().String(),
+		devices:    devices,
+		newCodec:   newCodec,
+		packetConn: conn,
+	}
+	s.wg.Add(1)
+	go s.servePackets()
+	return s
+}
+
+// Close shuts down the server and closes all connections. It blocks until all
+// connections have been served.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	if s.packetConn != nil {
+		err = s.packetConn.Close()
+	}
+	s.wg.Wait()
+	return err
+}
+
+// CloseConnections closes all open connections while the server keeps
+// accepting new ones, e.g. to test reconnects.
+func (s *Server) CloseConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
+
+func (s *Server) accept() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	c := s.newCodec()
+	r := bufio.NewReader(conn)
+	for {
+		aduRequest, err := c.readFrame(r)
+		if err != nil {
+			return
+		}
+		aduResponse, err := s.handle(c, aduRequest)
+		if err != nil {
+			continue
+		}
+		if aduResponse != nil {
+			if _, err = conn.Write(aduResponse); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *Server) servePackets() {
+	defer s.wg.Done()
+
+	c := s.newCodec()
+	var buf [udpMaxLength]byte
+	for {
+		n, addr, err := s.packetConn.ReadFrom(buf[:])
+		if err != nil {
+			return
+		}
+		aduResponse, err := s.handle(c, append([]byte(nil), buf[:n]...))
+		if err != nil || aduResponse == nil {
+			continue
+		}
+		if _, err = s.packetConn.WriteTo(aduResponse, addr); err != nil {
+			return
+		}
+	}
+}
+
+// handle returns the response ADU to a request ADU, or nil if the request is
+// not answered.
+func (s *Server) handle(c codec, aduRequest []byte) ([]byte, error) {
+	slaveID, request, err := c.decode(aduRequest)
+	if err != nil {
+		return nil, err
+	}
+	if slaveID == 0 && !c.gateway() {
+		// broadcast, processed by all devices and never answered
+		for _, device := range s.devices {
+			device.Handle(slaveID, request)
+		}
+		return nil, nil
+	}
+	device, ok := s.devices[slaveID]
+	if !ok {
+		if !c.gateway() {
+			return nil, nil
+		}
+		return c.encode(aduRequest, slaveID, Exception(request.FunctionCode, modbus.ExceptionCodeGatewayTargetDeviceFailedToRespond))
+	}
+	return c.encode(aduRequest, slaveID, device.Handle(slaveID, request))
+}
+
+// tcpCodec handles modbus TCP frames.
+type tcpCodec struct {
+	packager modbus.Packager
+}
+
+func (c *tcpCodec) readFrame(r *bufio.Reader) ([]byte, error) {
+	header := make([]byte, tcpHeaderSize, tcpMaxLength)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	length := int(binary.BigEndian.Uint16(header[4:]))
+	if length < 2 || tcpHeaderSize-1+length > tcpMaxLength {
+		return nil, fmt.Errorf("modbustest: invalid length %d", length)
+	}
+	adu := header[:tcpHeaderSize-1+length]
+	_, err := io.ReadFull(r, adu[tcpHeaderSize:])
+	return adu, err
+}
+
+func (c *tcpCodec) decode(adu []byte) (byte, *modbus.ProtocolDataUnit, error) {
+	if len(adu) <= tcpHeaderSize {
+		return 0, nil, fmt.Errorf("modbustest: frame too short")
+	}
+	pdu, err := c.packager.Decode(adu)
+	return adu[6], pdu, err
+}
+
+func (c *tcpCodec) encode(aduRequest []byte, slaveID byte, pdu *modbus.ProtocolDataUnit) ([]byte, error) {
+	c.packager.SetSlave(slaveID)
+	adu, err := c.packager.Encode(pdu)
+	if err != nil {
+		return nil, err
+	}
+	// answer with the transaction id of the request
+	copy(adu[:2], aduRequest[:2])
+	return adu, nil
+}
+
+func (c *tcpCodec) gateway() bool {
+	return true
+}
+
+// rtuCodec handles modbus RTU frames.
+type rtuCodec struct {
+	packager modbus.Packager
+}
+
+func (c *rtuCodec) readFrame(r *bufio.Reader) ([]byte, error) {
+	length, err := rtuFrameLength(r, false)
+	if err != nil {
+		return nil, err
+	}
+	if length < 0 {
+		// unknown function code, take what has been received
+		length = r.Buffered()
+	}
+	if length > rtuMaxLength {
+		return nil, fmt.Errorf("modbustest: invalid length %d", length)
+	}
+	adu := make([]byte, length)
+	_, err = io.ReadFull(r, adu)
+	return adu, err
+}
+
+// rtuPDULength returns the length of the PDU starting with pdu, the length
+// needed to determine it if pdu is too short, or -1 if it is unknown.
+type rtuPDULength func(pdu []byte) int
+
+// rtuFraming describes the length of the requests and responses of a
+// function code.
+type rtuFraming struct {
+	request, response rtuPDULength
+}
+
+// rtuFramings maps the function codes supported by the client to the length
+// of their frames.
+var rtuFramings = map[byte]rtuFraming{
+	modbus.FuncCodeReadCoils:            {rtuFixedLength(5), rtuByteCountLength(1)},
+	modbus.FuncCodeReadDiscreteInputs:   {rtuFixedLength(5), rtuByteCountLength(1)},
+	modbus.FuncCodeReadHoldingRegisters: {rtuFixedLength(5), rtuByteCountLength(1)},
+	modbus.FuncCodeReadInputRegisters:   {rtuFixedLength(5), rtuByteCountLength(1)},
+	modbus.FuncCodeWriteSingleCoil:      {rtuFixedLength(5), rtuFixedLength(5)},
+	modbus.FuncCodeWriteSingleRegister:  {rtuFixedLength(5), rtuFixedLength(5)},
+	// address, quantity and byte count
+	modbus.FuncCodeWriteMultipleCoils:     {rtuByteCountLength(5), rtuFixedLength(5)},
+	modbus.FuncCodeWriteMultipleRegisters: {rtuByteCountLength(5), rtuFixedLength(5)},
+	modbus.FuncCodeMaskWriteRegister:      {rtuFixedLength(7), rtuFixedLength(7)},
+	// read address, read quantity, write address, write quantity and byte count
+	modbus.FuncCodeReadWriteMultipleRegisters: {rtuByteCountLength(9), rtuByteCountLength(1)},
+	modbus.FuncCodeReadFIFOQueue:              {rtuFixedLength(3), rtuFIFOLength},
+	modbus.FuncCodeReportServerID:             {rtuFixedLength(1), rtuByteCountLength(1)},
+	modbus.FuncCodeReadDeviceIdentification:   {rtuFixedLength(4), rtuMEILength},
+}
+
+// rtuFixedLength returns the length of frames of fixed size.
+func rtuFixedLength(length int) rtuPDULength {
+	return func([]byte) int {
+		return length
+	}
+}
+
+// rtuByteCountLength returns the length of frames with a byte count at
+// offset, counting the bytes following it.
+func rtuByteCountLength(offset int) rtuPDULength {
+	return func(pdu []byte) int {
+		if len(pdu) <= offset {
+			return offset + 1
+		}
+		return offset + 1 + int(pdu[offset])
+	}
+}
+
+// rtuFIFOLength is the length of Read FIFO Queue responses, with a two byte
+// byte count.
+func rtuFIFOLength(pdu []byte) int {
+	if len(pdu) < 3 {
+		return 3
+	}
+	return 3 + int(binary.BigEndian.Uint16(pdu[1:]))
+}
+
+// rtuMEILength is the length of Read Device Identification responses, made
+// of a header and a list of objects each prefixed by its id and length.
+func rtuMEILength(pdu []byte) int {
+	// function code, MEI type, read device id code, conformity level,
+	// more follows, next object id, number of objects
+	length := 7
+	if len(pdu) < length {
+		return length
+	}
+	for i := 0; i < int(pdu[6]); i++ {
+		if len(pdu) < length+2 {
+			return length + 2
+		}
+		length += 2 + int(pdu[length+1])
+	}
+	return length
+}
+
+// rtuFrameLength returns the length of the next RTU request or response
+// frame, peeking at its content as far as necessary, or -1 if its function
+// code is unknown.
+func rtuFrameLength(r *bufio.Reader, response bool) (int, error) {
+	header, err := r.Peek(2)
+	if err != nil {
+		return 0, err
+	}
+	if response && header[1]&0x80 != 0 {
+		// slave id, function code, exception code and CRC
+		return 5, nil
+	}
+	framing, ok := rtuFramings[header[1]]
+	if !ok {
+		return -1, nil
+	}
+	length := framing.request
+	if response {
+		length = framing.response
+	}
+	pdu := header[1:]
+	for {
+		n := length(pdu)
+		if n <= len(pdu) {
+			// slave id and CRC
+			return 1 + n + 2, nil
+		}
+		if 1+n+2 > rtuMaxLength {
+			return 0, fmt.Errorf("modbustest: invalid length %d", 1+n+2)
+		}
+		adu, err := r.Peek(1 + n)
+		if err != nil {
+			return 0, err
+		}
+		pdu = adu[1:]
+	}
+}
+
+func (c *rtuCodec) decode(adu []byte) (byte, *modbus.ProtocolDataUnit, error) {
+	if len(adu) < 4 {
+		return 0, nil, fmt.Errorf("modbustest: frame too short")
+	}
+	pdu, err := c.packager.Decode(adu)
+	return adu[0], pdu, err
+}
+
+func (c *rtuCodec) encode(_ []byte, slaveID byte, pdu *modbus.ProtocolDataUnit) ([]byte, error) {
+	c.packager.SetSlave(slaveID)
+	return c.packager.Encode(pdu)
+}
+
+func (c *rtuCodec) gateway() bool {
+	return false
+}
+
+// asciiCodec handles modbus ASCII frames.
+type asciiCodec struct {
+	packager modbus.Packager
+}
+
+func (c *asciiCodec) readFrame(r *bufio.Reader) ([]byte, error) {
+	for {
+		adu, err := r.ReadBytes('\n')
+		if err != nil {
+			return nil, err
+		}
+		// skip everything before the start of a frame
+		for i, b := range adu {
+			if b == ':' {
+				return adu[i:], nil
+			}
+		}
+	}
+}
+
+func (c *asciiCodec) decode(adu []byte) (byte, *modbus.ProtocolDataUnit, error) {
+	if len(adu) < 9 {
+		return 0, nil, fmt.Errorf("modbustest: frame too short")
+	}
+	var slaveID [1]byte
+	if _, err := hex.Decode(slaveID[:], adu[1:3]); err != nil {
+		return 0, nil, err
+	}
+	pdu, err := c.packager.Decode(adu)
+	return slaveID[0], pdu, err
+}
+
+func (c *asciiCodec) encode(_ []byte, slaveID byte, pdu *modbus.ProtocolDataUnit) ([]byte, error) {
+	c.packager.SetSlave(slaveID)
+	return c.packager.Encode(pdu)
+}
+
+func (c *asciiCodec) gateway() bool {
+	return false
+}
@@ -0,0 +1,176 @@
+package modbustest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/grid-x/modbus"
+)
+
+func TestServers(t *testing.T) {
+	tests := []struct {
+		name       string
+		newServer  func(map[byte]*Device) *Server
+		newHandler func(address string) modbus.ClientHandler
+	}{
+		{
+			name:      "TCP",
+			newServer: NewTCPServer,
+			newHandler: func(address string) modbus.ClientHandler {
+				return modbus.NewTCPClientHandler(address)
+			},
+		},
+		{
+			name:      "RTUOverTCP",
+			newServer: NewRTUOverTCPServer,
+			newHandler: func(address string) modbus.ClientHandler {
+				return modbus.NewRTUOverTCPClientHandler(address)
+			},
+		},
+		{
+			name:      "ASCIIOverTCP",
+			newServer: NewASCIIOverTCPServer,
+			newHandler: func(address string) modbus.ClientHandler {
+				return modbus.NewASCIIOverTCPClientHandler(address)
+			},
+		},
+		{
+			name:      "RTUOverUDP",
+			newServer: NewRTUOverUDPServer,
+			newHandler: func(address string) modbus.ClientHandler {
+				return modbus.NewRTUOverUDPClientHandler(address)
+			},
+		},
+		{
+			name:      "TCPOverUDP",
+			newServer: NewTCPOverUDPServer,
+			newHandler: func(address string) modbus.ClientHandler {
+				return modbus.NewTCPOverUDPClientHandler(address)
+			},
+		},
+		{
+			name:      "ASCIIOverUDP",
+			newServer: NewASCIIOverUDPServer,
+			newHandler: func(address string) modbus.ClientHandler {
+				return modbus.NewASCIIOverUDPClientHandler(address)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first, second := NewDevice(), NewDevice()
+			first.SetHoldingRegisters(100, 0xCAFE, 0xBABE)
+			second.SetInputRegisters(7, 42)
+			server := tt.newServer(map[byte]*Device{1: first, 2: second})
+			defer server.Close()
+
+			handler := tt.newHandler(server.Addr)
+			handler.SetSlave(1)
+			client := modbus.NewClient(handler)
+			ctx := context.Background()
+
+			results, err := client.ReadHoldingRegisters(ctx, 100, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := []byte{0xCA, 0xFE, 0xBA, 0xBE}; !reflect.DeepEqual(results, want) {
+				t.Fatalf("expected % x, got % x", want, results)
+			}
+			if _, err = client.WriteMultipleCoils(ctx, 3, 10, []byte{0x05, 0x02}); err != nil {
+				t.Fatal(err)
+			}
+			want := []bool{true, false, true, false, false, false, false, false, false, true}
+			if got := first.Coils(3, 10); !reflect.DeepEqual(got, want) {
+				t.Fatalf("expected coils %v, got %v", want, got)
+			}
+
+			handler.SetSlave(2)
+			if results, err = client.ReadInputRegisters(ctx, 7, 1); err != nil {
+				t.Fatal(err)
+			}
+			if want := []byte{0, 42}; !reflect.DeepEqual(results, want) {
+				t.Fatalf("expected % x, got % x", want, results)
+			}
+
+			first.AssertRequests(t,
+				Request{SlaveID: 1, FunctionCode: modbus.FuncCodeReadHoldingRegisters, Data: []byte{0, 100, 0, 2}},
+				Request{SlaveID: 1, FunctionCode: modbus.FuncCodeWriteMultipleCoils, Data: []byte{0, 3, 0, 10, 2, 0x05, 0x02}},
+			)
+			second.AssertRequestCount(t, modbus.FuncCodeReadInputRegisters, 1)
+		})
+	}
+}
+
+func TestServerUnknownSlave(t *testing.T) {
+	devices := map[byte]*Device{1: NewDevice()}
+
+	tcp := NewTCPServer(devices)
+	defer tcp.Close()
+	handler := modbus.NewTCPClientHandler(tcp.Addr)
+	handler.SlaveID = 9
+	_, err := modbus.NewClient(handler).ReadCoils(context.Background(), 0, 1)
+	var mbErr *modbus.Error
+	if !errors.As(err, &mbErr) || mbErr.ExceptionCode != modbus.ExceptionCodeGatewayTargetDeviceFailedToRespond {
+		t.Fatalf("expected gateway exception, got %v", err)
+	}
+
+	rtu := NewRTUOverTCPServer(devices)
+	defer rtu.Close()
+	rtuHandler := modbus.NewRTUOverTCPClientHandler(rtu.Addr)
+	rtuHandler.SlaveID = 9
+	rtuHandler.Timeout = 100 * time.Millisecond
+	if _, err = modbus.NewClient(rtuHandler).ReadCoils(context.Background(), 0, 1); err == nil {
+		t.Fatal("expected timeout")
+	}
+}
+
+func TestRTUFrameLength(t *testing.T) {
+	device := NewDevice()
+	device.SetFIFOQueue(1, 0x01B8, 0x1284)
+	device.SetDeviceIdentification(map[byte]string{0: "grid-x", 1: "gx-1", 2: "1.0"})
+	device.SetServerID([]byte{0x2A, 0xFF, 'x'})
+	requests := []*modbus.ProtocolDataUnit{
+		{FunctionCode: modbus.FuncCodeReadCoils, Data: []byte{0, 0, 0, 10}},
+		{FunctionCode: modbus.FuncCodeReadDiscreteInputs, Data: []byte{0, 0, 0, 10}},
+		{FunctionCode: modbus.FuncCodeReadHoldingRegisters, Data: []byte{0, 0, 0, 2}},
+		{FunctionCode: modbus.FuncCodeReadInputRegisters, Data: []byte{0, 0, 0, 2}},
+		{FunctionCode: modbus.FuncCodeWriteSingleCoil, Data: []byte{0, 1, 0xFF, 0}},
+		{FunctionCode: modbus.FuncCodeWriteSingleRegister, Data: []byte{0, 1, 0, 7}},
+		{FunctionCode: modbus.FuncCodeWriteMultipleCoils, Data: []byte{0, 0, 0, 10, 2, 0xFF, 0x03}},
+		{FunctionCode: modbus.FuncCodeWriteMultipleRegisters, Data: []byte{0, 0, 0, 2, 4, 0, 1, 0, 2}},
+		{FunctionCode: modbus.FuncCodeMaskWriteRegister, Data: []byte{0, 0, 0, 0xFF, 0, 0x10}},
+		{FunctionCode: modbus.FuncCodeReadWriteMultipleRegisters, Data: []byte{0, 0, 0, 2, 0, 4, 0, 1, 2, 0, 9}},
+		{FunctionCode: modbus.FuncCodeReadFIFOQueue, Data: []byte{0, 1}},
+		{FunctionCode: modbus.FuncCodeReportServerID},
+		{FunctionCode: modbus.FuncCodeReadDeviceIdentification, Data: []byte{0x0E, 0x01, 0x00}},
+		{FunctionCode: modbus.FuncCodeReadCoils, Data: []byte{0xFF, 0xFF, 0, 10}},
+	}
+	packager := modbus.NewRTUClientHandler("")
+	packager.SlaveID = 1
+	// frameLength returns the length found for pdu, followed by another frame
+	frameLength := func(pdu *modbus.ProtocolDataUnit, response bool) (int, int) {
+		adu, err := packager.Encode(pdu)
+		if err != nil {
+			t.Fatal(err)
+		}
+		length, err := rtuFrameLength(bufio.NewReader(bytes.NewReader(append(adu, adu...))), response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(adu), length
+	}
+	for _, request := range requests {
+		if expected, length := frameLength(request, false); length != expected {
+			t.Errorf("function code %d: expected request length %d, got %d", request.FunctionCode, expected, length)
+		}
+		response := device.Handle(1, request)
+		if expected, length := frameLength(response, true); length != expected {
+			t.Errorf("function code %d: expected response length %d, got %d", response.FunctionCode, expected, length)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package modbus
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+const (
+	// Default interval between two polls of a busy device
+	pollInterval = 100 * time.Millisecond
+	// Default duration after which polling a busy device gives up
+	pollTimeout = 10 * time.Second
+
+	// commEventCounterBusy is the status word of a device that is still
+	// processing a previous command
+	commEventCounterBusy = 0xFFFF
+)
+
+// PollMode selects how a client finds out that a busy device has completed a
+// long-running operation.
+type PollMode int
+
+const (
+	// PollReissue reissues the request until the device answers it.
+	PollReissue PollMode = iota
+	// PollCommEventCounter polls with Get Comm Event Counter (0x0B) until the
+	// device reports that it is no longer busy, then reissues the request to
+	// obtain its response. Get Comm Event Counter is only defined for serial
+	// line devices.
+	PollCommEventCounter
+)
+
+// AcknowledgePolling configures the completion polling of a client, see
+// WithAcknowledgePolling.
+type AcknowledgePolling struct {
+	Mode PollMode
+	// Interval is the time between two polls.
+	Interval time.Duration
+	// Timeout is the overall duration after which polling gives up.
+	Timeout time.Duration
+}
+
+// WithAcknowledgePolling returns a ClientOption that makes the client poll a
+// device answering with an Acknowledge or Server Device Busy exception until
+// it completes the request, instead of returning the exception. If the device
+// is still busy after polling.Timeout the exception is returned wrapped in an
+// error that also matches ErrTimeout.
+//
+// Polling is implemented as an interceptor and added to the chain like
+// WithInterceptors.
+func WithAcknowledgePolling(polling AcknowledgePolling) ClientOption {
+	if polling.Interval <= 0 {
+		polling.Interval = pollInterval
+	}
+	if polling.Timeout <= 0 {
+		polling.Timeout = pollTimeout
+	}
+	return WithInterceptors(polling.intercept)
+}
+
+func (p AcknowledgePolling) intercept(ctx context.Context, _ *RequestInfo, request *ProtocolDataUnit, invoker Invoker) (*ProtocolDataUnit, error) {
+	response, err := invoker(ctx, request)
+	if !isPending(err) {
+		return response, err
+	}
+
+	deadline := time.Now().Add(p.Timeout)
+	for {
+		if time.Now().Add(p.Interval).After(deadline) {
+			return nil, errorf(ErrTimeout, "modbus: device still busy after %v: %w", p.Timeout, err)
+		}
+		timer := time.NewTimer(p.Interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+
+		if p.Mode == PollCommEventCounter {
+			var busy bool
+			if busy, err = pollCommEventCounter(ctx, invoker); busy || isPending(err) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		if response, err = invoker(ctx, request); !isPending(err) {
+			return response, err
+		}
+	}
+}
+
+// pollCommEventCounter reports whether the device is still busy according to
+// the status word of Get Comm Event Counter.
+//
+// Request:
+//
+//	Function code         : 1 byte (0x0B)
+//
+// Response:
+//
+//	Function code         : 1 byte (0x0B)
+//	Status                : 2 bytes
+//	Event count           : 2 bytes
+func pollCommEventCounter(ctx context.Context, invoker Invoker) (bool, error) {
+	response, err := invoker(ctx, &ProtocolDataUnit{FunctionCode: FuncCodeGetCommEventCounter})
+	if err != nil {
+		return false, err
+	}
+	if len(response.Data) != 4 {
+		return false, &DataSizeError{ExpectedBytes: 4, ActualBytes: len(response.Data)}
+	}
+	return binary.BigEndian.Uint16(response.Data) == commEventCounterBusy, nil
+}
+
+// isPending reports whether err is an exception of a device that is still
+// processing a request.
+func isPending(err error) bool {
+	return errors.Is(err, ErrAcknowledge) || errors.Is(err, ErrServerDeviceBusy)
+}
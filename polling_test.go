@@ -0,0 +1,96 @@
+package modbus
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAcknowledgePollingReissue(t *testing.T) {
+	pending := 2
+	tr := &pduTransporter{respond: func(request *ProtocolDataUnit) *ProtocolDataUnit {
+		if pending > 0 {
+			pending--
+			return &ProtocolDataUnit{FunctionCode: request.FunctionCode | 0x80, Data: []byte{ExceptionCodeAcknowledge}}
+		}
+		return &ProtocolDataUnit{FunctionCode: request.FunctionCode, Data: request.Data}
+	}}
+	client := NewClient2(&rtuPackager{SlaveID: 1}, tr, WithAcknowledgePolling(AcknowledgePolling{Interval: time.Millisecond}))
+
+	if _, err := client.WriteSingleRegister(context.Background(), 1, 2); err != nil {
+		t.Fatal(err)
+	}
+	if len(tr.requests) != 3 {
+		t.Fatalf("expected 3 requests, got %d", len(tr.requests))
+	}
+}
+
+func TestAcknowledgePollingCommEventCounter(t *testing.T) {
+	busyPolls := 2
+	tr := &pduTransporter{respond: func(request *ProtocolDataUnit) *ProtocolDataUnit {
+		switch {
+		case request.FunctionCode == FuncCodeGetCommEventCounter && busyPolls > 0:
+			busyPolls--
+			return &ProtocolDataUnit{FunctionCode: request.FunctionCode, Data: []byte{0xFF, 0xFF, 0, 1}}
+		case request.FunctionCode == FuncCodeGetCommEventCounter:
+			return &ProtocolDataUnit{FunctionCode: request.FunctionCode, Data: []byte{0, 0, 0, 2}}
+		case busyPolls > 0:
+			return &ProtocolDataUnit{FunctionCode: request.FunctionCode | 0x80, Data: []byte{ExceptionCodeServerDeviceBusy}}
+		}
+		return &ProtocolDataUnit{FunctionCode: request.FunctionCode, Data: []byte{2, 0xCA, 0xFE}}
+	}}
+	client := NewClient2(&rtuPackager{SlaveID: 1}, tr, WithAcknowledgePolling(AcknowledgePolling{
+		Mode:     PollCommEventCounter,
+		Interval: time.Millisecond,
+	}))
+
+	results, err := client.ReadHoldingRegisters(context.Background(), 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0] != 0xCA {
+		t.Fatalf("unexpected results % x", results)
+	}
+	var functionCodes []byte
+	for _, request := range tr.requests {
+		functionCodes = append(functionCodes, request.FunctionCode)
+	}
+	want := []byte{FuncCodeReadHoldingRegisters, FuncCodeGetCommEventCounter, FuncCodeGetCommEventCounter,
+		FuncCodeGetCommEventCounter, FuncCodeReadHoldingRegisters}
+	if string(functionCodes) != string(want) {
+		t.Fatalf("expected function codes % x, got % x", want, functionCodes)
+	}
+}
+
+func TestAcknowledgePollingTimeout(t *testing.T) {
+	tr := &pduTransporter{respond: func(request *ProtocolDataUnit) *ProtocolDataUnit {
+		return &ProtocolDataUnit{FunctionCode: request.FunctionCode | 0x80, Data: []byte{ExceptionCodeServerDeviceBusy}}
+	}}
+	client := NewClient2(&rtuPackager{SlaveID: 1}, tr, WithAcknowledgePolling(AcknowledgePolling{
+		Interval: 5 * time.Millisecond,
+		Timeout:  20 * time.Millisecond,
+	}))
+
+	_, err := client.ReadCoils(context.Background(), 0, 1)
+	if !errors.Is(err, ErrTimeout) || !errors.Is(err, ErrServerDeviceBusy) {
+		t.Fatalf("expected timeout with busy exception, got %v", err)
+	}
+	if len(tr.requests) < 2 {
+		t.Fatalf("expected the request to be reissued, got %d requests", len(tr.requests))
+	}
+}
+
+func TestAcknowledgePollingPassesOtherErrors(t *testing.T) {
+	tr := &pduTransporter{respond: func(request *ProtocolDataUnit) *ProtocolDataUnit {
+		return &ProtocolDataUnit{FunctionCode: request.FunctionCode | 0x80, Data: []byte{ExceptionCodeIllegalDataAddress}}
+	}}
+	client := NewClient2(&rtuPackager{SlaveID: 1}, tr, WithAcknowledgePolling(AcknowledgePolling{}))
+
+	if _, err := client.ReadCoils(context.Background(), 0, 1); !errors.Is(err, ErrIllegalDataAddress) {
+		t.Fatalf("expected illegal data address, got %v", err)
+	}
+	if len(tr.requests) != 1 {
+		t.Fatalf("expected a single request, got %d", len(tr.requests))
+	}
+}
@@ -0,0 +1,301 @@
+package modbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Default distance of the addresses sampled by a RegisterProber
+const probeStep = 64
+
+// AddressRange is a range of consecutive readable addresses, from First to
+// Last inclusive.
+type AddressRange struct {
+	First, Last uint16
+}
+
+// ProbedTable is the outcome of probing one data table of a device.
+type ProbedTable struct {
+	// FunctionCode is the read function code of the table.
+	FunctionCode byte
+	// Supported is false if the device rejects the function code.
+	Supported bool
+	// MaxQuantity is the largest quantity the device accepts per request,
+	// up to the protocol limit.
+	MaxQuantity uint16
+	Ranges      []AddressRange
+}
+
+// RegisterMap is the draft register map of a device found by a
+// RegisterProber.
+type RegisterMap struct {
+	Tables []ProbedTable
+}
+
+// String returns the register map as text, one table per paragraph and one
+// range per line.
+func (m *RegisterMap) String() string {
+	var b strings.Builder
+	for i, table := range m.Tables {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		name := probeTableNames[table.FunctionCode]
+		if !table.Supported {
+			fmt.Fprintf(&b, "%s: not supported\n", name)
+			continue
+		}
+		fmt.Fprintf(&b, "%s: max %d per request\n", name, table.MaxQuantity)
+		for _, r := range table.Ranges {
+			fmt.Fprintf(&b, "  %d-%d (%d)\n", r.First, r.Last, int(r.Last)-int(r.First)+1)
+		}
+	}
+	return b.String()
+}
+
+// probeTableNames are the names of the tables by read function code.
+var probeTableNames = map[byte]string{
+	FuncCodeReadCoils:            "coils",
+	FuncCodeReadDiscreteInputs:   "discrete inputs",
+	FuncCodeReadHoldingRegisters: "holding registers",
+	FuncCodeReadInputRegisters:   "input registers",
+}
+
+// probeMaxQuantities are the protocol limits of the read requests.
+var probeMaxQuantities = map[byte]int{
+	FuncCodeReadCoils:            2000,
+	FuncCodeReadDiscreteInputs:   2000,
+	FuncCodeReadHoldingRegisters: 125,
+	FuncCodeReadInputRegisters:   125,
+}
+
+// RegisterProber finds the readable addresses of a device. It reads single
+// addresses every Step addresses, then binary-searches the bounds of the
+// ranges around the readable ones. Addresses a device rejects with an illegal
+// data address exception are not readable, requests rejected with an illegal
+// data value exception exceed the maximum quantity of the device, unless they
+// read a single address, which is then not readable either. Ranges lying
+// between two sampled addresses are missed.
+type RegisterProber struct {
+	// FunctionCodes are the read function codes of the tables to probe,
+	// defaults to coils, discrete inputs, holding and input registers.
+	FunctionCodes []byte
+	// First and Last are the bounds of the probed addresses, inclusive.
+	First, Last uint16
+	// Step is the distance of the sampled addresses.
+	Step uint16
+
+	client Client
+}
+
+// NewRegisterProber allocates a RegisterProber sampling all addresses of all
+// tables with client every 64 addresses.
+func NewRegisterProber(client Client) *RegisterProber {
+	return &RegisterProber{
+		FunctionCodes: []byte{FuncCodeReadCoils, FuncCodeReadDiscreteInputs, FuncCodeReadHoldingRegisters, FuncCodeReadInputRegisters},
+		First:         0,
+		Last:          0xFFFF,
+		Step:          probeStep,
+		client:        client,
+	}
+}
+
+// Probe probes the tables and returns the register map. It stops at the first
+// error other than an illegal data address or illegal data value exception,
+// returning the tables probed so far with the error.
+func (p *RegisterProber) Probe(ctx context.Context) (*RegisterMap, error) {
+	registerMap := &RegisterMap{}
+	for _, functionCode := range p.FunctionCodes {
+		if _, ok := probeMaxQuantities[functionCode]; !ok {
+			return registerMap, fmt.Errorf("modbus: function code '%v' is not a read function", functionCode)
+		}
+		table, err := p.probeTable(ctx, functionCode)
+		registerMap.Tables = append(registerMap.Tables, *table)
+		if err != nil {
+			return registerMap, err
+		}
+	}
+	return registerMap, nil
+}
+
+// probeTable probes the table read by functionCode.
+func (p *RegisterProber) probeTable(ctx context.Context, functionCode byte) (*ProbedTable, error) {
+	t := &tableProbe{client: p.client, functionCode: functionCode, maxQuantity: probeMaxQuantities[functionCode]}
+	table := &ProbedTable{FunctionCode: functionCode, Supported: true}
+	first, last := int(p.First), int(p.Last)
+
+	// Devices check the quantity before the addresses, so a request rejected
+	// with an illegal data address exception has an acceptable quantity.
+	_, err := t.readable(ctx, first, min(t.maxQuantity, 0x10000-first))
+	switch {
+	case errors.Is(err, ErrIllegalFunction):
+		table.Supported = false
+		return table, nil
+	case err != nil && !errors.Is(err, errProbeQuantity):
+		return table, err
+	}
+	table.MaxQuantity = uint16(t.maxQuantity)
+
+	step := max(int(p.Step), 1)
+	// unreadable is the highest address known to be unreadable
+	unreadable := first - 1
+	for address := first; address <= last; address += step {
+		ok, err := t.readable(ctx, address, 1)
+		if err != nil {
+			return table, err
+		}
+		if !ok {
+			unreadable = address
+			continue
+		}
+		start, err := t.searchStart(ctx, unreadable, address)
+		if err != nil {
+			return table, err
+		}
+		end, err := t.searchEnd(ctx, address, last)
+		if err != nil {
+			return table, err
+		}
+		table.MaxQuantity = uint16(t.maxQuantity)
+		table.Ranges = append(table.Ranges, AddressRange{First: uint16(start), Last: uint16(end)})
+		// continue sampling after the range, its end + 1 is unreadable
+		unreadable = end + 1
+		for address+step <= end {
+			address += step
+		}
+	}
+	return table, nil
+}
+
+// tableProbe reads one table of a device.
+type tableProbe struct {
+	client       Client
+	functionCode byte
+	// maxQuantity is the largest quantity accepted by the device
+	maxQuantity int
+}
+
+// errProbeQuantity is returned by readable if the quantity exceeds the
+// maximum of the device, which has been lowered.
+var errProbeQuantity = errors.New("modbus: quantity exceeds maximum")
+
+// readable reports whether quantity addresses starting at address can be
+// read.
+func (t *tableProbe) readable(ctx context.Context, address, quantity int) (bool, error) {
+	err := t.read(ctx, address, quantity)
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, ErrIllegalDataAddress):
+		return false, nil
+	case errors.Is(err, ErrIllegalDataValue) && quantity > 1:
+		if err := t.searchMaxQuantity(ctx, address, quantity); err != nil {
+			return false, err
+		}
+		return false, errProbeQuantity
+	case errors.Is(err, ErrIllegalDataValue):
+		// a single address is never too many, some devices reject unmapped
+		// addresses as illegal data value
+		return false, nil
+	}
+	return false, err
+}
+
+// searchMaxQuantity lowers maxQuantity to the largest quantity below
+// rejected the device accepts at address.
+func (t *tableProbe) searchMaxQuantity(ctx context.Context, address, rejected int) error {
+	accepted := 1
+	for accepted+1 < rejected {
+		quantity := (accepted + rejected) / 2
+		err := t.read(ctx, address, quantity)
+		switch {
+		case err == nil, errors.Is(err, ErrIllegalDataAddress):
+			accepted = quantity
+		case errors.Is(err, ErrIllegalDataValue):
+			rejected = quantity
+		default:
+			return err
+		}
+	}
+	t.maxQuantity = accepted
+	return nil
+}
+
+// searchStart returns the first readable address after unreadable, given
+// that address is readable.
+func (t *tableProbe) searchStart(ctx context.Context, unreadable, address int) (int, error) {
+	for unreadable+1 < address {
+		middle := (unreadable + address) / 2
+		ok, err := t.readable(ctx, middle, 1)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			address = middle
+		} else {
+			unreadable = middle
+		}
+	}
+	return address, nil
+}
+
+// searchEnd returns the last readable address of the range starting at the
+// readable address, not beyond last.
+func (t *tableProbe) searchEnd(ctx context.Context, address, last int) (int, error) {
+	// extend the range by blocks of the maximum quantity
+	end := address
+	for end < last {
+		quantity := min(t.maxQuantity, last-end)
+		ok, err := t.readable(ctx, end+1, quantity)
+		if errors.Is(err, errProbeQuantity) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			break
+		}
+		end += quantity
+	}
+	if end == last {
+		return end, nil
+	}
+	// the next block contains the end, search the readable quantity
+	readable, unreadable := 0, min(t.maxQuantity, last-end)
+	for readable+1 < unreadable {
+		quantity := (readable + unreadable) / 2
+		ok, err := t.readable(ctx, end+1, quantity)
+		if errors.Is(err, errProbeQuantity) {
+			// the device checks the addresses first, shrink the block
+			unreadable = min(unreadable, t.maxQuantity+1)
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			readable = quantity
+		} else {
+			unreadable = quantity
+		}
+	}
+	return end + readable, nil
+}
+
+// read reads quantity addresses starting at address.
+func (t *tableProbe) read(ctx context.Context, address, quantity int) (err error) {
+	switch t.functionCode {
+	case FuncCodeReadCoils:
+		_, err = t.client.ReadCoils(ctx, uint16(address), uint16(quantity))
+	case FuncCodeReadDiscreteInputs:
+		_, err = t.client.ReadDiscreteInputs(ctx, uint16(address), uint16(quantity))
+	case FuncCodeReadHoldingRegisters:
+		_, err = t.client.ReadHoldingRegisters(ctx, uint16(address), uint16(quantity))
+	case FuncCodeReadInputRegisters:
+		_, err = t.client.ReadInputRegisters(ctx, uint16(address), uint16(quantity))
+	}
+	return
+}
@@ -0,0 +1,116 @@
+package modbus
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// rangeResponder answers read requests of the tables in ranges, rejecting
+// quantities above maxQuantity and addresses outside the ranges.
+func rangeResponder(ranges map[byte][]AddressRange, maxQuantity uint16) func(*ProtocolDataUnit) *ProtocolDataUnit {
+	return func(request *ProtocolDataUnit) *ProtocolDataUnit {
+		exception := func(code byte) *ProtocolDataUnit {
+			return &ProtocolDataUnit{FunctionCode: request.FunctionCode | 0x80, Data: []byte{code}}
+		}
+		tableRanges, ok := ranges[request.FunctionCode]
+		if !ok {
+			return exception(ExceptionCodeIllegalFunction)
+		}
+		address, quantity := binary.BigEndian.Uint16(request.Data), binary.BigEndian.Uint16(request.Data[2:])
+		if quantity > maxQuantity {
+			return exception(ExceptionCodeIllegalDataValue)
+		}
+		last := int(address) + int(quantity) - 1
+		for _, r := range tableRanges {
+			if address < r.First || last > int(r.Last) {
+				continue
+			}
+			count := int(quantity) * 2
+			if request.FunctionCode == FuncCodeReadCoils || request.FunctionCode == FuncCodeReadDiscreteInputs {
+				count = (int(quantity) + 7) / 8
+			}
+			return &ProtocolDataUnit{FunctionCode: request.FunctionCode, Data: append([]byte{byte(count)}, make([]byte, count)...)}
+		}
+		return exception(ExceptionCodeIllegalDataAddress)
+	}
+}
+
+func TestRegisterProber(t *testing.T) {
+	ranges := map[byte][]AddressRange{
+		FuncCodeReadCoils:            {{First: 0, Last: 9}},
+		FuncCodeReadHoldingRegisters: {{First: 100, Last: 299}, {First: 1000, Last: 1000}, {First: 40001, Last: 40130}},
+		FuncCodeReadInputRegisters:   {{First: 65500, Last: 65535}},
+	}
+	tr := &pduTransporter{respond: rangeResponder(ranges, 100)}
+	prober := NewRegisterProber(NewClient2(&rtuPackager{SlaveID: 1}, tr))
+	prober.Step = 50
+
+	registerMap, err := prober.Probe(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &RegisterMap{Tables: []ProbedTable{
+		{FunctionCode: FuncCodeReadCoils, Supported: true, MaxQuantity: 100, Ranges: ranges[FuncCodeReadCoils]},
+		{FunctionCode: FuncCodeReadDiscreteInputs},
+		{FunctionCode: FuncCodeReadHoldingRegisters, Supported: true, MaxQuantity: 100, Ranges: ranges[FuncCodeReadHoldingRegisters]},
+		{FunctionCode: FuncCodeReadInputRegisters, Supported: true, MaxQuantity: 100, Ranges: ranges[FuncCodeReadInputRegisters]},
+	}}
+	if !reflect.DeepEqual(registerMap, expected) {
+		t.Errorf("expected %+v, got %+v", expected, registerMap)
+	}
+
+	text := "coils: max 100 per request\n  0-9 (10)\n\n" +
+		"discrete inputs: not supported\n\n" +
+		"holding registers: max 100 per request\n  100-299 (200)\n  1000-1000 (1)\n  40001-40130 (130)\n\n" +
+		"input registers: max 100 per request\n  65500-65535 (36)\n"
+	if got := registerMap.String(); got != text {
+		t.Errorf("expected %q, got %q", text, got)
+	}
+}
+
+func TestRegisterProberIllegalDataValue(t *testing.T) {
+	ranges := map[byte][]AddressRange{
+		FuncCodeReadHoldingRegisters: {{First: 100, Last: 299}},
+	}
+	respond := rangeResponder(ranges, 100)
+	// the device rejects single unmapped addresses as illegal data value
+	tr := &pduTransporter{respond: func(request *ProtocolDataUnit) *ProtocolDataUnit {
+		response := respond(request)
+		if binary.BigEndian.Uint16(request.Data[2:]) == 1 && response.FunctionCode&0x80 != 0 {
+			response.Data[0] = ExceptionCodeIllegalDataValue
+		}
+		return response
+	}}
+	prober := NewRegisterProber(NewClient2(&rtuPackager{SlaveID: 1}, tr))
+	prober.FunctionCodes = []byte{FuncCodeReadHoldingRegisters}
+	prober.Last = 999
+	prober.Step = 50
+
+	registerMap, err := prober.Probe(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &RegisterMap{Tables: []ProbedTable{
+		{FunctionCode: FuncCodeReadHoldingRegisters, Supported: true, MaxQuantity: 100, Ranges: ranges[FuncCodeReadHoldingRegisters]},
+	}}
+	if !reflect.DeepEqual(registerMap, expected) {
+		t.Errorf("expected %+v, got %+v", expected, registerMap)
+	}
+}
+
+func TestRegisterProberStopsOnError(t *testing.T) {
+	errBroken := errors.New("broken")
+	handler := &scriptedHandler{errs: []error{errBroken}}
+	prober := NewRegisterProber(NewClient(handler))
+
+	registerMap, err := prober.Probe(context.Background())
+	if !errors.Is(err, errBroken) {
+		t.Errorf("expected %v, got %v", errBroken, err)
+	}
+	if len(registerMap.Tables) != 1 || len(handler.sends) != 1 {
+		t.Errorf("expected the probe to stop after one request, got %d requests and %+v", len(handler.sends), registerMap)
+	}
+}
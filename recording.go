@@ -0,0 +1,330 @@
+package modbus
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// TransportType names the framing of recorded ADUs.
+type TransportType string
+
+const (
+	// TransportTCP are modbus TCP frames with MBAP header.
+	TransportTCP TransportType = "tcp"
+	// TransportRTU are modbus RTU frames, also used by RTU over TCP and UDP.
+	TransportRTU TransportType = "rtu"
+	// TransportASCII are modbus ASCII frames, also used by ASCII over TCP.
+	TransportASCII TransportType = "ascii"
+)
+
+// Frame is a single recorded request/response exchange.
+type Frame struct {
+	// Time is the time the request was sent.
+	Time time.Time
+	// Transport is the framing of Request and Response.
+	Transport TransportType
+	Request   []byte
+	Response  []byte
+	// Error is the message of the transport error, if any.
+	Error string
+	// ErrorKind classifies Error, e.g. "timeout", so that the replayed error
+	// matches the same sentinel error. Empty if it matches none.
+	ErrorKind string
+}
+
+// recordedErrorKinds are the sentinel errors matched by recorded errors, by
+// their name in a Frame.
+var recordedErrorKinds = []struct {
+	name string
+	err  error
+}{
+	{"timeout", ErrTimeout},
+	{"checksum", ErrChecksum},
+	{"framing", ErrFraming},
+	{"response_mismatch", ErrResponseMismatch},
+	{"connection_lost", ErrConnectionLost},
+	{"canceled", context.Canceled},
+}
+
+// errorKind returns the name of the first sentinel error matched by err.
+func errorKind(err error) string {
+	err = transportError(err)
+	for _, kind := range recordedErrorKinds {
+		if errors.Is(err, kind.err) {
+			return kind.name
+		}
+	}
+	return ""
+}
+
+// frameJSON is the JSON encoding of a Frame, one object per line.
+type frameJSON struct {
+	Time      time.Time     `json:"time"`
+	Transport TransportType `json:"transport"`
+	Request   string        `json:"request"`
+	Response  string        `json:"response,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	ErrorKind string        `json:"error_kind,omitempty"`
+}
+
+// MarshalJSON encodes the frame with hex encoded ADUs.
+func (f Frame) MarshalJSON() ([]byte, error) {
+	return json.Marshal(frameJSON{
+		Time:      f.Time,
+		Transport: f.Transport,
+		Request:   hex.EncodeToString(f.Request),
+		Response:  hex.EncodeToString(f.Response),
+		Error:     f.Error,
+		ErrorKind: f.ErrorKind,
+	})
+}
+
+// UnmarshalJSON decodes a frame encoded by MarshalJSON.
+func (f *Frame) UnmarshalJSON(data []byte) (err error) {
+	var v frameJSON
+	if err = json.Unmarshal(data, &v); err != nil {
+		return
+	}
+	f.Time, f.Transport, f.Error, f.ErrorKind = v.Time, v.Transport, v.Error, v.ErrorKind
+	if f.Request, err = hex.DecodeString(v.Request); err != nil {
+		return fmt.Errorf("modbus: request: %w", err)
+	}
+	if f.Response, err = hex.DecodeString(v.Response); err != nil {
+		return fmt.Errorf("modbus: response: %w", err)
+	}
+	return
+}
+
+// RecordingTransporter wraps a Transporter and writes every exchange as a
+// JSON encoded Frame per line to a writer:
+//
+//	handler := modbus.NewRTUClientHandler("/dev/ttyUSB0")
+//	recorder := modbus.NewRecordingTransporter(handler, modbus.TransportRTU, file)
+//	client := modbus.NewClient2(handler, recorder)
+type RecordingTransporter struct {
+	Transporter
+	// Transport is written as the transport type of every frame.
+	Transport TransportType
+
+	mu  sync.Mutex
+	w   io.Writer
+	err error
+	now func() time.Time
+}
+
+// NewRecordingTransporter allocates a RecordingTransporter writing the frames
+// of transporter to w.
+func NewRecordingTransporter(transporter Transporter, transport TransportType, w io.Writer) *RecordingTransporter {
+	return &RecordingTransporter{
+		Transporter: transporter,
+		Transport:   transport,
+		w:           w,
+		now:         time.Now,
+	}
+}
+
+// Send sends the request with the wrapped transporter and records the exchange.
+// Recording failures do not fail the request, they are reported by Err.
+func (r *RecordingTransporter) Send(ctx context.Context, aduRequest []byte) (aduResponse []byte, err error) {
+	frame := Frame{
+		Time:      r.now(),
+		Transport: r.Transport,
+		Request:   append([]byte(nil), aduRequest...),
+	}
+	aduResponse, err = r.Transporter.Send(ctx, aduRequest)
+	frame.Response = aduResponse
+	if err != nil {
+		frame.Error = err.Error()
+		frame.ErrorKind = errorKind(err)
+	}
+
+	line, merr := json.Marshal(frame)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if merr == nil {
+		_, merr = r.w.Write(append(line, '\n'))
+	}
+	if merr != nil && r.err == nil {
+		r.err = fmt.Errorf("modbus: recording frame: %w", merr)
+	}
+	return
+}
+
+// Err returns the first error that occurred while recording.
+func (r *RecordingTransporter) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// ReadFrames reads all frames written by a RecordingTransporter.
+func ReadFrames(rd io.Reader) ([]Frame, error) {
+	var frames []Frame
+	scanner := bufio.NewScanner(rd)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var frame Frame
+		if err := json.Unmarshal(scanner.Bytes(), &frame); err != nil {
+			return nil, fmt.Errorf("modbus: frame on line %d: %w", line, err)
+		}
+		frames = append(frames, frame)
+	}
+	return frames, scanner.Err()
+}
+
+// ReplayMode selects how a ReplayTransporter matches requests to frames.
+type ReplayMode int
+
+const (
+	// ReplayStrict expects the requests in recorded order and byte by byte
+	// identical to the recorded requests, except for the transaction id of
+	// TCP frames, which is rewritten in the response.
+	ReplayStrict ReplayMode = iota
+	// ReplayLenient answers a request with the first unused frame having an
+	// equal request, regardless of order. The transaction id of TCP frames is
+	// ignored for matching and rewritten in the response.
+	ReplayLenient
+)
+
+// ReplayMismatchError is returned by a ReplayTransporter if no recorded frame
+// matches the request.
+type ReplayMismatchError struct {
+	Request []byte
+	// Expected is the next recorded request in strict mode, nil otherwise.
+	Expected []byte
+}
+
+// Error implements the error interface
+func (e *ReplayMismatchError) Error() string {
+	if e.Expected == nil {
+		return fmt.Sprintf("modbus: no recorded frame for request '% x'", e.Request)
+	}
+	return fmt.Sprintf("modbus: request '% x' does not match recorded request '% x'", e.Request, e.Expected)
+}
+
+// RecordedError is returned by a ReplayTransporter for frames that were
+// recorded with a transport error. The returned error also matches the
+// sentinel error of the recorded ErrorKind, e.g. ErrTimeout.
+type RecordedError string
+
+// Error implements the error interface
+func (e RecordedError) Error() string {
+	return string(e)
+}
+
+// ReplayTransporter is a Transporter serving recorded responses, e.g. to
+// reproduce a field conversation in tests without hardware:
+//
+//	frames, err := modbus.ReadFrames(file)
+//	replay := modbus.NewReplayTransporter(frames, modbus.ReplayStrict)
+//	client := modbus.NewClient2(modbus.NewRTUClientHandler(""), replay)
+type ReplayTransporter struct {
+	mode ReplayMode
+
+	mu     sync.Mutex
+	frames []Frame
+	used   []bool
+	next   int
+}
+
+// NewReplayTransporter allocates a ReplayTransporter serving frames.
+func NewReplayTransporter(frames []Frame, mode ReplayMode) *ReplayTransporter {
+	return &ReplayTransporter{
+		mode:   mode,
+		frames: frames,
+		used:   make([]bool, len(frames)),
+	}
+}
+
+// Send returns the recorded response of the matching frame.
+func (r *ReplayTransporter) Send(ctx context.Context, aduRequest []byte) (aduResponse []byte, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.match(aduRequest)
+	if i < 0 {
+		mismatch := &ReplayMismatchError{Request: aduRequest}
+		if r.mode == ReplayStrict && r.next < len(r.frames) {
+			mismatch.Expected = r.frames[r.next].Request
+		}
+		return nil, mismatch
+	}
+	r.used[i] = true
+	for r.next < len(r.used) && r.used[r.next] {
+		r.next++
+	}
+
+	frame := r.frames[i]
+	if frame.Error != "" {
+		return nil, replayedError(frame)
+	}
+	aduResponse = append([]byte(nil), frame.Response...)
+	if frame.Transport == TransportTCP && len(aduResponse) >= 2 && len(aduRequest) >= 2 {
+		copy(aduResponse[:2], aduRequest[:2])
+	}
+	return
+}
+
+// replayedError returns the recorded error of frame, matching the sentinel
+// error of its kind.
+func replayedError(frame Frame) error {
+	for _, kind := range recordedErrorKinds {
+		if kind.name == frame.ErrorKind {
+			return &kindError{kind: kind.err, err: RecordedError(frame.Error)}
+		}
+	}
+	return RecordedError(frame.Error)
+}
+
+// match returns the index of the frame answering the request or -1.
+func (r *ReplayTransporter) match(aduRequest []byte) int {
+	if r.mode == ReplayStrict {
+		if r.next < len(r.frames) && r.frames[r.next].matches(aduRequest) {
+			return r.next
+		}
+		return -1
+	}
+	for i := r.next; i < len(r.frames); i++ {
+		if !r.used[i] && r.frames[i].matches(aduRequest) {
+			return i
+		}
+	}
+	return -1
+}
+
+// matches reports whether the recorded request equals aduRequest, ignoring
+// the transaction id of TCP frames.
+func (f *Frame) matches(aduRequest []byte) bool {
+	if f.Transport == TransportTCP && len(f.Request) >= 2 && len(aduRequest) >= 2 {
+		return bytes.Equal(f.Request[2:], aduRequest[2:])
+	}
+	return bytes.Equal(f.Request, aduRequest)
+}
+
+// Remaining returns the number of recorded frames not yet replayed.
+func (r *ReplayTransporter) Remaining() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := 0
+	for _, used := range r.used {
+		if !used {
+			n++
+		}
+	}
+	return n
+}
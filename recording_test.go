@@ -0,0 +1,156 @@
+package modbus
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	tr := &pduTransporter{respond: func(request *ProtocolDataUnit) *ProtocolDataUnit {
+		if request.FunctionCode == FuncCodeReadCoils {
+			return &ProtocolDataUnit{FunctionCode: FuncCodeReadCoils | 0x80, Data: []byte{ExceptionCodeIllegalDataAddress}}
+		}
+		return &ProtocolDataUnit{FunctionCode: request.FunctionCode, Data: []byte{2, 0xCA, 0xFE}}
+	}}
+	var file bytes.Buffer
+	recorder := NewRecordingTransporter(tr, TransportRTU, &file)
+	recorder.now = func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	ctx := context.Background()
+	client := NewClient2(&rtuPackager{SlaveID: 5}, recorder)
+	if _, err := client.ReadHoldingRegisters(ctx, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ReadCoils(ctx, 0, 1); err == nil {
+		t.Fatal("expected exception")
+	}
+	if err := recorder.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	frames, err := ReadFrames(&file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 2 {
+		t.Fatalf("expected 2 frames, got %v", len(frames))
+	}
+	if frames[0].Transport != TransportRTU || !frames[0].Time.Equal(recorder.now()) {
+		t.Fatalf("unexpected frame %+v", frames[0])
+	}
+
+	replay := NewReplayTransporter(frames, ReplayStrict)
+	client = NewClient2(&rtuPackager{SlaveID: 5}, replay)
+	// out of order requests do not match in strict mode
+	_, err = client.ReadCoils(ctx, 0, 1)
+	var mismatch *ReplayMismatchError
+	if !errors.As(err, &mismatch) || !reflect.DeepEqual(mismatch.Expected, frames[0].Request) {
+		t.Fatalf("expected mismatch error, got %v", err)
+	}
+	results, err := client.ReadHoldingRegisters(ctx, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(results, []byte{0xCA, 0xFE}) {
+		t.Fatalf("unexpected results % x", results)
+	}
+	var mbErr *Error
+	if _, err = client.ReadCoils(ctx, 0, 1); !errors.As(err, &mbErr) || mbErr.ExceptionCode != ExceptionCodeIllegalDataAddress {
+		t.Fatalf("expected replayed exception, got %v", err)
+	}
+	if n := replay.Remaining(); n != 0 {
+		t.Fatalf("expected all frames to be replayed, %v remaining", n)
+	}
+}
+
+func TestReplayLenientTCP(t *testing.T) {
+	recorded := &tcpPackager{SlaveID: 1}
+	var frames []Frame
+	for _, pdu := range []*ProtocolDataUnit{
+		{FunctionCode: FuncCodeReadInputRegisters, Data: dataBlock(0, 1)},
+		{FunctionCode: FuncCodeReadHoldingRegisters, Data: dataBlock(0, 1)},
+	} {
+		request, err := recorded.Encode(pdu)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response := append([]byte(nil), request[:tcpHeaderSize+1]...)
+		response = append(response, 2, 0, pdu.FunctionCode)
+		response[5] = 5
+		frames = append(frames, Frame{Transport: TransportTCP, Request: request, Response: response})
+	}
+	frames = append(frames, Frame{Transport: TransportTCP, Request: frames[0].Request, Error: "i/o timeout"})
+
+	// a fresh packager starts with other transaction ids than the recording
+	packager := &tcpPackager{SlaveID: 1}
+	packager.transactionID = 1000
+	client := NewClient2(packager, NewReplayTransporter(frames, ReplayLenient))
+	ctx := context.Background()
+
+	results, err := client.ReadHoldingRegisters(ctx, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(results, []byte{0, FuncCodeReadHoldingRegisters}) {
+		t.Fatalf("unexpected results % x", results)
+	}
+	if _, err = client.ReadInputRegisters(ctx, 0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = client.ReadInputRegisters(ctx, 0, 1); err != RecordedError("i/o timeout") {
+		t.Fatalf("expected recorded error, got %v", err)
+	}
+	var mismatch *ReplayMismatchError
+	if _, err = client.ReadInputRegisters(ctx, 0, 1); !errors.As(err, &mismatch) {
+		t.Fatalf("expected mismatch error, got %v", err)
+	}
+}
+
+func TestReplayStrictTCPIgnoresTransactionID(t *testing.T) {
+	recorded := &tcpPackager{SlaveID: 1}
+	recorded.transactionID = 41
+	request, err := recorded.Encode(&ProtocolDataUnit{FunctionCode: FuncCodeReadHoldingRegisters, Data: dataBlock(0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	response := append(append([]byte(nil), request[:tcpHeaderSize+1]...), 2, 0xCA, 0xFE)
+	response[5] = 5
+	frames := []Frame{{Transport: TransportTCP, Request: request, Response: response}}
+
+	client := NewClient2(&tcpPackager{SlaveID: 1}, NewReplayTransporter(frames, ReplayStrict))
+	results, err := client.ReadHoldingRegisters(context.Background(), 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(results, []byte{0xCA, 0xFE}) {
+		t.Fatalf("unexpected results % x", results)
+	}
+}
+
+func TestReplayRestoresErrorKind(t *testing.T) {
+	tr := &scriptedHandler{errs: []error{&timeoutError{}}}
+	var file bytes.Buffer
+	recorder := NewRecordingTransporter(tr, TransportRTU, &file)
+	client := NewClient2(&rtuPackager{SlaveID: 1}, recorder)
+	ctx := context.Background()
+	if _, err := client.ReadHoldingRegisters(ctx, 0, 1); err == nil {
+		t.Fatal("expected timeout")
+	}
+
+	frames, err := ReadFrames(&file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 1 || frames[0].ErrorKind != "timeout" {
+		t.Fatalf("expected a timeout frame, got %+v", frames)
+	}
+	client = NewClient2(&rtuPackager{SlaveID: 1}, NewReplayTransporter(frames, ReplayStrict))
+	_, err = client.ReadHoldingRegisters(ctx, 0, 1)
+	if !errors.Is(err, ErrTimeout) || !errors.Is(err, RecordedError("i/o timeout")) {
+		t.Fatalf("expected replayed timeout, got %v", err)
+	}
+}
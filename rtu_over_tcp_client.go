@@ -6,10 +6,18 @@ package modbus
 
 import (
 	"context"
-	"io"
+	"log/slog"
 	"time"
 )
 
+const (
+	// Default silence ending responses of unknown length
+	rtuTCPFrameGap = 100 * time.Millisecond
+	// Time to wait for leftover bytes before sending the request following a
+	// failed one
+	rtuTCPDrainTimeout = time.Millisecond
+)
+
 // RTUOverTCPClientHandler implements Packager and Transporter interface.
 type RTUOverTCPClientHandler struct {
 	rtuPackager
@@ -22,7 +30,8 @@ func NewRTUOverTCPClientHandler(address string) *RTUOverTCPClientHandler {
 	handler.Address = address
 	handler.Timeout = tcpTimeout
 	handler.IdleTimeout = tcpIdleTimeout
-	handler.Dial = defaultDialFunc(handler.Timeout)
+	handler.FrameGap = rtuTCPFrameGap
+	handler.Dial = handler.dial
 	return handler
 }
 
@@ -35,12 +44,43 @@ func RTUOverTCPClient(address string) Client {
 // rtuTCPTransporter implements Transporter interface.
 type rtuTCPTransporter struct {
 	tcpTransporter
+	// Silence after which the received bytes are taken as the response if
+	// its length is not known from the function code. If zero, such
+	// responses end at the CRC check only when Timeout is reached.
+	FrameGap time.Duration
+
+	// stale is set if the last request failed after it was sent, so that its
+	// response may still arrive
+	stale bool
+}
+
+// discardLeftover reads and discards the bytes pending on the connection.
+func (mb *rtuTCPTransporter) discardLeftover() error {
+	var data [rtuMaxSize]byte
+	for {
+		if err := mb.conn.SetReadDeadline(time.Now().Add(rtuTCPDrainTimeout)); err != nil {
+			return err
+		}
+		n, err := mb.conn.Read(data[:])
+		if n > 0 {
+			if logger := mb.logger(); logger.Enabled(context.Background(), slog.LevelDebug) {
+				logger.Debug("modbus: discarding leftover bytes", rtuFrameAttrs(LogDirectionRecv, data[:n])...)
+			}
+		}
+		if err != nil {
+			if isTimeout(err) {
+				return nil
+			}
+			return err
+		}
+	}
 }
 
 // Send sends data to server and ensures adequate response for request type
 func (mb *rtuTCPTransporter) Send(ctx context.Context, aduRequest []byte) (aduResponse []byte, err error) {
 	mb.mu.Lock()
 	defer mb.mu.Unlock()
+	defer func() { err = transportError(err) }()
 
 	// Establish a new connection if not connected
 	if err = mb.connect(ctx); err != nil {
@@ -50,6 +90,14 @@ func (mb *rtuTCPTransporter) Send(ctx context.Context, aduRequest []byte) (aduRe
 	mb.lastActivity = time.Now()
 	mb.startCloseTimer()
 
+	// Discard bytes left over from a failed request, e.g. a late response.
+	// Later ones are skipped by the CRC resynchronisation of readRTUFrame.
+	if mb.stale {
+		if err = mb.discardLeftover(); err != nil {
+			return
+		}
+		mb.stale = false
+	}
 	// Set write and read timeout
 	if mb.Timeout > 0 {
 		if err = mb.conn.SetDeadline(mb.lastActivity.Add(mb.Timeout)); err != nil {
@@ -58,43 +106,33 @@ func (mb *rtuTCPTransporter) Send(ctx context.Context, aduRequest []byte) (aduRe
 	}
 
 	// Send the request
-	mb.logf("modbus: send % x\n", aduRequest)
-	if _, err = mb.conn.Write(aduRequest); err != nil {
-		return
+	if logger := mb.logger(); logger.Enabled(ctx, slog.LevelDebug) {
+		logger.Debug("modbus: send", rtuFrameAttrs(LogDirectionSend, aduRequest)...)
 	}
-	function := aduRequest[1]
-	functionFail := aduRequest[1] & 0x80
-	bytesToRead := calculateResponseLength(aduRequest)
-
-	var n, n1 int
-	var data [rtuMaxSize]byte
-	// We first read the minimum length and then read either the full package
-	// or the error package, depending on the error status (byte 2 of the response)
-	n, err = io.ReadAtLeast(mb.conn, data[:], rtuMinSize)
-	if err != nil {
+	if _, err = mb.conn.Write(aduRequest); err != nil {
 		return
 	}
-	// if the function is correct
-	if data[1] == function {
-		// we read the rest of the bytes
-		if n < bytesToRead {
-			if bytesToRead > rtuMinSize && bytesToRead <= rtuMaxSize {
-				n1, err = io.ReadFull(mb.conn, data[n:bytesToRead])
-				n += n1
-			}
+	mb.observer().RequestSent(len(aduRequest))
+	sent := time.Now()
+	defer func() {
+		mb.stale = err != nil
+		if err != nil {
+			mb.logger().Warn("modbus: read error", append(errorAttrs(err, ""), durationAttr(sent))...)
 		}
-	} else if data[1] == functionFail {
-		// for error we need to read 5 bytes
-		if n < rtuExceptionSize {
-			n1, err = io.ReadFull(mb.conn, data[n:rtuExceptionSize])
+		if isTimeout(err) {
+			mb.observer().Timeout()
 		}
-		n += n1
+	}()
+	deadline := time.Time{}
+	if mb.Timeout > 0 {
+		deadline = mb.lastActivity.Add(mb.Timeout)
 	}
-
-	if err != nil {
+	if aduResponse, err = readRTUFrame(mb.conn, aduRequest[0], aduRequest[1], deadline, mb.FrameGap); err != nil {
 		return
 	}
-	aduResponse = data[:n]
-	mb.logf("modbus: recv % x\n", aduResponse)
+	mb.observer().ResponseReceived(len(aduResponse), time.Since(sent))
+	if logger := mb.logger(); logger.Enabled(ctx, slog.LevelDebug) {
+		logger.Debug("modbus: recv", append(rtuFrameAttrs(LogDirectionRecv, aduResponse), durationAttr(sent))...)
+	}
 	return
 }
@@ -6,9 +6,9 @@ package modbus
 
 import (
 	"context"
-	"encoding/binary"
 	"fmt"
 	"io"
+	"log/slog"
 	"time"
 )
 
@@ -19,14 +19,6 @@ const (
 	rtuExceptionSize = 5
 )
 
-const (
-	stateSlaveID = 1 << iota
-	stateFunctionCode
-	stateReadLength
-	stateReadPayload
-	stateCRC
-)
-
 const (
 	readCoilsFunctionCode           = 0x01
 	readDiscreteInputsFunctionCode  = 0x02
@@ -41,6 +33,17 @@ const (
 
 	readWriteMultipleRegisterFunctionCode = 0x17
 	readFifoQueueFunctionCode             = 0x18
+
+	readExceptionStatusFunctionCode = 0x07
+	diagnosticsFunctionCode         = 0x08
+	getCommEventCounterFunctionCode = 0x0B
+	getCommEventLogFunctionCode     = 0x0C
+	reportServerIDFunctionCode      = 0x11
+
+	readFileRecordFunctionCode  = 0x14
+	writeFileRecordFunctionCode = 0x15
+
+	readDeviceIdentificationFunctionCode = 0x2B
 )
 
 // RTUClientHandler implements Packager and Transporter interface.
@@ -75,6 +78,11 @@ func (mb *rtuPackager) SetSlave(slaveID byte) {
 	mb.SlaveID = slaveID
 }
 
+// slave returns the modbus slave id used for the next client operations
+func (mb *rtuPackager) slave() byte {
+	return mb.SlaveID
+}
+
 // Encode encodes PDU in an RTU frame:
 //
 //	Slave Address   : 1 byte
@@ -108,12 +116,12 @@ func (mb *rtuPackager) Verify(aduRequest []byte, aduResponse []byte) (err error)
 	length := len(aduResponse)
 	// Minimum size (including address, function and CRC)
 	if length < rtuMinSize {
-		err = fmt.Errorf("modbus: response length '%v' does not meet minimum '%v'", length, rtuMinSize)
+		err = errorf(ErrFraming, "modbus: response length '%v' does not meet minimum '%v'", length, rtuMinSize)
 		return
 	}
 	// Slave address must match
 	if aduResponse[0] != aduRequest[0] {
-		err = fmt.Errorf("modbus: response slave id '%v' does not match request '%v'", aduResponse[0], aduRequest[0])
+		err = errorf(ErrResponseMismatch, "modbus: response slave id '%v' does not match request '%v'", aduResponse[0], aduRequest[0])
 		return
 	}
 	return
@@ -127,7 +135,7 @@ func (mb *rtuPackager) Decode(adu []byte) (pdu *ProtocolDataUnit, err error) {
 	crc.reset().pushBytes(adu[0 : length-2])
 	checksum := uint16(adu[length-1])<<8 | uint16(adu[length-2])
 	if checksum != crc.value() {
-		err = fmt.Errorf("modbus: response crc '%v' does not match expected '%v'", checksum, crc.value())
+		err = &ChecksumError{Kind: "crc", Got: checksum, Expected: crc.value()}
 		return
 	}
 	// Function code & data
@@ -140,6 +148,18 @@ func (mb *rtuPackager) Decode(adu []byte) (pdu *ProtocolDataUnit, err error) {
 // rtuSerialTransporter implements Transporter interface.
 type rtuSerialTransporter struct {
 	serialPort
+	// FrameTiming detects the end of responses by a silence of t3.5 on the
+	// line instead of by their content, and rejects responses with a silence
+	// above t1.5. It must not be changed once the port is open.
+	FrameTiming bool
+	// CharTimeout overrides t1.5 of FrameTiming, derived from BaudRate if zero.
+	CharTimeout time.Duration
+	// FrameTimeout overrides t3.5 of FrameTiming, derived from BaudRate if zero.
+	FrameTimeout time.Duration
+
+	frameReader *RTUFrameReader
+	// port generation of frameReader
+	frameReaderPort uint64
 }
 
 // InvalidLengthError is returned by readIncrementally when the modbus response would overflow buffer
@@ -153,18 +173,24 @@ func (e *InvalidLengthError) Error() string {
 	return fmt.Sprintf("invalid length received: %d", e.length)
 }
 
-// readIncrementally reads incrementally
+// Is reports whether target is ErrFraming.
+func (e *InvalidLengthError) Is(target error) bool {
+	return target == ErrFraming
+}
+
+// readIncrementally reads the response of slaveID to functionCode byte by
+// byte, skipping bytes until the slave id and function code are received.
+// The length of the response is taken from rtuResponseFraming.
 func readIncrementally(slaveID, functionCode byte, r io.Reader, deadline time.Time) ([]byte, error) {
 	if r == nil {
 		return nil, fmt.Errorf("reader is nil")
 	}
+	if _, ok := rtuResponseFraming[functionCode]; !ok {
+		return nil, errorf(ErrFraming, "functioncode not handled: %d", functionCode)
+	}
 
 	buf := make([]byte, 1)
-	data := make([]byte, rtuMaxSize)
-
-	state := stateSlaveID
-	var length, toRead byte
-	var n, crcCount int
+	data := make([]byte, 0, rtuMaxSize)
 
 	for {
 		if time.Now().After(deadline) { // Possible that serialport may spew data
@@ -175,79 +201,30 @@ func readIncrementally(slaveID, functionCode byte, r io.Reader, deadline time.Ti
 			return nil, err
 		}
 
-		switch state {
-		// expecting slaveID
-		case stateSlaveID:
-			// read slaveID
+		switch len(data) {
+		case 0:
+			// expecting slaveID
 			if buf[0] == slaveID {
-				state = stateFunctionCode
-				data[n] = buf[0]
-				n++
-				continue
+				data = append(data, buf[0])
 			}
-		case stateFunctionCode:
-			// read function code
-			if buf[0] == functionCode {
-				switch functionCode {
-				case readDiscreteInputsFunctionCode,
-					readCoilsFunctionCode,
-					readHoldingRegisterFunctionCode,
-					readInputRegisterFunctionCode,
-					readWriteMultipleRegisterFunctionCode,
-					readFifoQueueFunctionCode:
-
-					state = stateReadLength
-				case writeSingleCoilFunctionCode,
-					writeSingleRegisterFunctionCode,
-					writeMultipleRegisterFunctionCode,
-					writeMultipleCoilsFunctionCode:
-
-					state = stateReadPayload
-					toRead = 4
-				case maskWriteRegisterFunctionCode:
-					state = stateReadPayload
-					toRead = 6
-				default:
-					return nil, fmt.Errorf("functioncode not handled: %d", functionCode)
-				}
-				data[n] = buf[0]
-				n++
+			continue
+		case 1:
+			// expecting function code or exception
+			if buf[0] != functionCode && buf[0] != functionCode|0x80 {
 				continue
-			} else if buf[0] == functionCode+0x80 {
-				state = stateReadPayload
-				data[n] = buf[0]
-				n++
-				// only exception code left to read
-				toRead = 1
-			}
-		case stateReadLength:
-			// read length byte
-			length = buf[0]
-			// max length = rtuMaxSize - SlaveID(1) - FunctionCode(1) - length(1) - CRC(2)
-			if length > rtuMaxSize-5 || length == 0 {
-				return nil, &InvalidLengthError{length: length}
 			}
+		}
+		data = append(data, buf[0])
 
-			toRead = length
-			data[n] = length
-			n++
-			state = stateReadPayload
-		case stateReadPayload:
-			// read payload
-			data[n] = buf[0]
-			toRead--
-			n++
-			if toRead == 0 {
-				state = stateCRC
-			}
-		case stateCRC:
-			// read crc
-			data[n] = buf[0]
-			crcCount++
-			n++
-			if crcCount == 2 {
-				return data[:n], nil
-			}
+		length, err := rtuResponseLength(data)
+		if err != nil {
+			return nil, err
+		}
+		if length > rtuMaxSize {
+			return nil, errorf(ErrFraming, "modbus: response length '%v' must not be bigger than '%v'", length, rtuMaxSize)
+		}
+		if len(data) == length {
+			return data, nil
 		}
 	}
 }
@@ -255,6 +232,7 @@ func readIncrementally(slaveID, functionCode byte, r io.Reader, deadline time.Ti
 func (mb *rtuSerialTransporter) Send(ctx context.Context, aduRequest []byte) (aduResponse []byte, err error) {
 	mb.mu.Lock()
 	defer mb.mu.Unlock()
+	defer func() { err = transportError(err) }()
 
 	// Make sure port is connected
 	if err = mb.connect(ctx); err != nil {
@@ -267,8 +245,19 @@ func (mb *rtuSerialTransporter) Send(ctx context.Context, aduRequest []byte) (ad
 	linkRecoveryDeadline := time.Now().Add(mb.LinkRecoveryTimeout)
 
 	for {
+		var frames *RTUFrameReader
+		if mb.FrameTiming {
+			if frames, err = mb.timedFrameReader(); err != nil {
+				return
+			}
+			// Responses to earlier requests must not be taken for this one
+			frames.Discard()
+		}
+
 		// Send the request
-		mb.logf("modbus: send % x\n", aduRequest)
+		if logger := mb.logger(); logger.Enabled(ctx, slog.LevelDebug) {
+			logger.Debug("modbus: send", rtuFrameAttrs(LogDirectionSend, aduRequest)...)
+		}
 		if _, err = mb.port.Write(aduRequest); err != nil {
 			if mb.shouldRecover(err) {
 				if err = mb.reconnect(ctx, err, linkRecoveryDeadline); err != nil {
@@ -279,17 +268,45 @@ func (mb *rtuSerialTransporter) Send(ctx context.Context, aduRequest []byte) (ad
 
 			return
 		}
-		bytesToRead := calculateResponseLength(aduRequest)
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		case <-time.After(mb.calculateDelay(len(aduRequest) + bytesToRead)):
-		}
+		mb.observer().RequestSent(len(aduRequest))
+		sent := time.Now()
+		if frames != nil {
+			// The request is still being transmitted
+			frameCtx, cancel := context.WithTimeout(ctx, mb.calculateDelay(len(aduRequest))+mb.Timeout)
+			if mb.EchoSuppression {
+				var echo []byte
+				if echo, err = frames.ReadFrame(frameCtx); err == nil {
+					err = verifyEcho(aduRequest, echo)
+				}
+			}
+			if err == nil {
+				aduResponse, err = frames.ReadFrame(frameCtx)
+			}
+			cancel()
+		} else {
+			bytesToRead := calculateResponseLength(aduRequest)
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(mb.calculateDelay(len(aduRequest) + bytesToRead)):
+			}
 
-		connDeadline := time.Now().Add(mb.Timeout)
-		aduResponse, err = readIncrementally(aduRequest[0], aduRequest[1], mb.port, connDeadline)
+			connDeadline := time.Now().Add(mb.Timeout)
+			if err = mb.setReadDeadline(connDeadline); err != nil {
+				err = fmt.Errorf("modbus: set read deadline: %w", err)
+				return
+			}
+			if mb.EchoSuppression {
+				err = mb.readEcho(aduRequest)
+			}
+			if err == nil {
+				aduResponse, err = readIncrementally(aduRequest[0], aduRequest[1], mb.port, connDeadline)
+			}
+		}
 		if aduResponse != nil {
-			mb.logf("modbus: recv % x\n", aduResponse[:])
+			if logger := mb.logger(); logger.Enabled(ctx, slog.LevelDebug) {
+				logger.Debug("modbus: recv", append(rtuFrameAttrs(LogDirectionRecv, aduResponse), durationAttr(sent))...)
+			}
 		}
 
 		if err != nil {
@@ -300,14 +317,54 @@ func (mb *rtuSerialTransporter) Send(ctx context.Context, aduRequest []byte) (ad
 				continue
 			}
 			// Unknown error
-			mb.logf("modbus: read error: %v", err)
+			mb.logger().Warn("modbus: read error", append(errorAttrs(err, ""), durationAttr(sent))...)
+			if isTimeout(err) {
+				mb.observer().Timeout()
+			}
 			return
 		}
+		mb.observer().ResponseReceived(len(aduResponse), time.Since(sent))
 		return
 	}
 
 }
 
+// Close closes the serial port and stops the frame reader.
+func (mb *rtuSerialTransporter) Close() error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	if mb.frameReader != nil {
+		mb.frameReader.Close()
+		mb.frameReader = nil
+	}
+	return mb.close()
+}
+
+// timedFrameReader returns the RTUFrameReader of the open port. Caller must
+// hold the mutex.
+func (mb *rtuSerialTransporter) timedFrameReader() (*RTUFrameReader, error) {
+	if mb.frameReader == nil || mb.frameReaderPort != mb.generation {
+		if mb.frameReader != nil {
+			// The previous port is closed, stop reading it
+			mb.frameReader.Close()
+		}
+		// The reader waits for frames without deadline
+		if err := mb.setReadDeadline(time.Time{}); err != nil {
+			return nil, fmt.Errorf("modbus: set read deadline: %w", err)
+		}
+		mb.frameReader = NewRTUFrameReader(mb.port, mb.BaudRate)
+		mb.frameReaderPort = mb.generation
+	}
+	if mb.CharTimeout > 0 {
+		mb.frameReader.CharTimeout = mb.CharTimeout
+	}
+	if mb.FrameTimeout > 0 {
+		mb.frameReader.FrameTimeout = mb.FrameTimeout
+	}
+	return mb.frameReader, nil
+}
+
 // calculateDelay roughly calculates time needed for the next frame.
 // See MODBUS over Serial Line - Specification and Implementation Guide (page 13).
 func (mb *rtuSerialTransporter) calculateDelay(chars int) time.Duration {
@@ -323,32 +380,19 @@ func (mb *rtuSerialTransporter) calculateDelay(chars int) time.Duration {
 	return time.Duration(characterDelay*chars+frameDelay) * time.Microsecond
 }
 
-func calculateResponseLength(adu []byte) int {
-	length := rtuMinSize
-	switch adu[1] {
-	case FuncCodeReadDiscreteInputs,
-		FuncCodeReadCoils:
-		count := int(binary.BigEndian.Uint16(adu[4:]))
-		length += 1 + count/8
-		if count%8 != 0 {
-			length++
-		}
-	case FuncCodeReadInputRegisters,
-		FuncCodeReadHoldingRegisters,
-		FuncCodeReadWriteMultipleRegisters:
-		count := int(binary.BigEndian.Uint16(adu[4:]))
-		length += 1 + count*2
-	case FuncCodeWriteSingleCoil,
-		FuncCodeWriteMultipleCoils,
-		FuncCodeWriteSingleRegister,
-		FuncCodeWriteMultipleRegisters:
-		length += 4
-	case FuncCodeMaskWriteRegister:
-		length += 6
-	case FuncCodeReadFIFOQueue,
-		FuncCodeReadDeviceIdentification:
-		// undetermined
-	default:
+// calculateResponseLength returns the length of the response ADU expected
+// for the request ADU, or the minimum length if it cannot be determined.
+func calculateResponseLength(aduRequest []byte) int {
+	framing, ok := rtuResponseFraming[aduRequest[1]]
+	if !ok {
+		return rtuMinSize
+	}
+	var length int
+	if framing.expected != nil && len(aduRequest) >= 6 {
+		length = framing.expected(aduRequest[1:])
+	} else {
+		length, _ = framing.response(aduRequest[1:2])
 	}
-	return length
+	// slave id and CRC
+	return 1 + length + 2
 }
@@ -6,6 +6,7 @@ package modbus
 
 import (
 	"bytes"
+	"context"
 	"reflect"
 	"testing"
 	"time"
@@ -58,6 +59,9 @@ var responseLengthTests = []struct {
 	{[]byte{0x11, 6, 0, 1, 0, 3, 0x9A, 0x9B}, 8},
 	{[]byte{0x11, 0xF, 0, 0x13, 0, 0xA, 2, 0xCD, 1, 0xBF, 0xB}, 8},
 	{[]byte{0x11, 0x10, 0, 1, 0, 2, 4, 0, 0xA, 1, 2, 0xC6, 0xF0}, 8},
+	{[]byte{0x11, 0xB, 0x4C, 0x1B}, 8},
+	{[]byte{0x11, 0x18, 0x04, 0xDE, 0x06, 0xD0}, 6},
+	{[]byte{0x11, 0x2B, 0x0E, 0x01, 0x00, 0x70, 0x77}, 5},
 }
 
 func TestCalculateResponseLength(t *testing.T) {
@@ -312,6 +316,37 @@ func Test_readIncrementally(t *testing.T) {
 				0xd7, 0xd7, // crc (not valid but not tested)
 			},
 		},
+		{
+			description:  "fifo queue with two byte byte count",
+			slaveID:      0x01,
+			functionCode: 0x18,
+			data:         []byte{0xFF, 0x01, 0x18, 0x00, 0x06, 0x00, 0x02, 0x01, 0xB8, 0x12, 0x84, 0xAB, 0xCD, 0xFF},
+			want:         []byte{0x01, 0x18, 0x00, 0x06, 0x00, 0x02, 0x01, 0xB8, 0x12, 0x84, 0xAB, 0xCD},
+		},
+		{
+			description:  "device identification object list",
+			slaveID:      0x01,
+			functionCode: 0x2B,
+			data: []byte{
+				0x01, 0x2B, 0x0E, 0x01, 0x01, 0x00, 0x00, 0x02,
+				0x00, 0x03, 'A', 'B', 'C',
+				0x01, 0x01, 'X',
+				0x12, 0x34, 0xFF,
+			},
+			want: []byte{
+				0x01, 0x2B, 0x0E, 0x01, 0x01, 0x00, 0x00, 0x02,
+				0x00, 0x03, 'A', 'B', 'C',
+				0x01, 0x01, 'X',
+				0x12, 0x34,
+			},
+		},
+		{
+			description:  "exception",
+			slaveID:      0x01,
+			functionCode: 0x2B,
+			data:         []byte{0x01, 0xAB, 0x01, 0x12, 0x34, 0xFF},
+			want:         []byte{0x01, 0xAB, 0x01, 0x12, 0x34},
+		},
 		{
 			description:  "data over buffer size",
 			slaveID:      0x0F,
@@ -350,3 +385,40 @@ func Test_readIncrementally(t *testing.T) {
 		})
 	}
 }
+
+func TestRTUClientVariableLengthResponses(t *testing.T) {
+	// client for requests of size bytes answered with response
+	client := func(size int, response *ProtocolDataUnit) Client {
+		handler := NewRTUStreamClientHandler(streamResponder(t, size, func(request []byte) []byte {
+			adu, _ := (&rtuPackager{SlaveID: request[0]}).Encode(response)
+			return adu
+		}))
+		handler.SlaveID = 1
+		t.Cleanup(func() { handler.Close() })
+		return NewClient(handler)
+	}
+	ctx := context.Background()
+
+	fifo := client(6, &ProtocolDataUnit{FunctionCode: FuncCodeReadFIFOQueue, Data: []byte{0, 6, 0, 2, 0x01, 0xB8, 0x12, 0x84}})
+	results, err := fifo.ReadFIFOQueue(ctx, 0x04DE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(results, []byte{0x01, 0xB8, 0x12, 0x84}) {
+		t.Fatalf("unexpected fifo contents % x", results)
+	}
+
+	identification := client(7, &ProtocolDataUnit{FunctionCode: FuncCodeReadDeviceIdentification, Data: []byte{
+		0x0E, 0x01, 0x01, 0x00, 0x00, 0x03,
+		0x00, 0x03, 'A', 'C', 'M',
+		0x01, 0x02, 'P', '1',
+		0x02, 0x03, '1', '.', '0',
+	}})
+	objects, err := identification.ReadDeviceIdentification(ctx, ReadDeviceIDCodeBasic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(objects[0]) != "ACM" || string(objects[2]) != "1.0" {
+		t.Fatalf("unexpected objects %q", objects)
+	}
+}
@@ -0,0 +1,249 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"net"
+	"time"
+)
+
+// errRTULengthUnknown is returned for responses whose length cannot be
+// determined from their content.
+var errRTULengthUnknown = errorf(ErrFraming, "modbus: response length unknown")
+
+// rtuPDULength returns the length of the response PDU starting with pdu, or
+// the length needed to determine it if pdu is too short.
+type rtuPDULength func(pdu []byte) (length int, err error)
+
+// rtuFraming describes the length of the responses to a function code.
+type rtuFraming struct {
+	// response returns the length of a response PDU
+	response rtuPDULength
+	// expected returns the length of the response PDU to a request PDU.
+	// If nil, the minimum length of the response is expected.
+	expected func(request []byte) int
+}
+
+// rtuResponseFraming maps function codes to the length of their responses.
+var rtuResponseFraming = map[byte]rtuFraming{
+	readCoilsFunctionCode:                 {rtuByteCountLength, rtuBitsExpected},
+	readDiscreteInputsFunctionCode:        {rtuByteCountLength, rtuBitsExpected},
+	readHoldingRegisterFunctionCode:       {rtuByteCountLength, rtuRegistersExpected},
+	readInputRegisterFunctionCode:         {rtuByteCountLength, rtuRegistersExpected},
+	readWriteMultipleRegisterFunctionCode: {rtuByteCountLength, rtuRegistersExpected},
+	getCommEventLogFunctionCode:           {rtuByteCountLength, nil},
+	reportServerIDFunctionCode:            {rtuByteCountLength, nil},
+	readFileRecordFunctionCode:            {rtuByteCountLength, nil},
+	writeFileRecordFunctionCode:           {rtuByteCountLength, nil},
+	readExceptionStatusFunctionCode:       {rtuFixedLength(2), nil},
+	diagnosticsFunctionCode:               {rtuFixedLength(5), nil},
+	writeSingleCoilFunctionCode:           {rtuFixedLength(5), nil},
+	writeSingleRegisterFunctionCode:       {rtuFixedLength(5), nil},
+	writeMultipleCoilsFunctionCode:        {rtuFixedLength(5), nil},
+	writeMultipleRegisterFunctionCode:     {rtuFixedLength(5), nil},
+	getCommEventCounterFunctionCode:       {rtuFixedLength(5), nil},
+	maskWriteRegisterFunctionCode:         {rtuFixedLength(7), nil},
+	readFifoQueueFunctionCode:             {rtuFIFOLength, nil},
+	readDeviceIdentificationFunctionCode:  {rtuMEILength, nil},
+}
+
+// rtuByteCountLength is the length of responses with a byte count after the
+// function code.
+func rtuByteCountLength(pdu []byte) (int, error) {
+	if len(pdu) < 2 {
+		return 2, nil
+	}
+	// max byte count = rtuMaxSize - SlaveID(1) - FunctionCode(1) - length(1) - CRC(2)
+	if count := pdu[1]; count > rtuMaxSize-5 || count == 0 {
+		return 0, &InvalidLengthError{length: count}
+	}
+	return 2 + int(pdu[1]), nil
+}
+
+// rtuFixedLength returns the length of responses of fixed size.
+func rtuFixedLength(length int) rtuPDULength {
+	return func([]byte) (int, error) {
+		return length, nil
+	}
+}
+
+// rtuFIFOLength is the length of Read FIFO Queue responses, with a two byte
+// byte count.
+func rtuFIFOLength(pdu []byte) (int, error) {
+	if len(pdu) < 3 {
+		return 3, nil
+	}
+	return 3 + int(binary.BigEndian.Uint16(pdu[1:])), nil
+}
+
+// rtuMEILength is the length of Read Device Identification responses, made
+// of a header and a list of objects each prefixed by its id and length.
+// Other MEI types are not known.
+func rtuMEILength(pdu []byte) (int, error) {
+	if len(pdu) < 2 {
+		return 2, nil
+	}
+	if pdu[1] != byte(meiTypeReadDeviceIdentification) {
+		return 0, errRTULengthUnknown
+	}
+	// function code, MEI type, read device id code, conformity level,
+	// more follows, next object id, number of objects
+	length := 7
+	if len(pdu) < length {
+		return length, nil
+	}
+	for i := 0; i < int(pdu[6]); i++ {
+		if len(pdu) < length+2 {
+			return length + 2, nil
+		}
+		length += 2 + int(pdu[length+1])
+	}
+	return length, nil
+}
+
+// rtuBitsExpected is the length of responses to bit reads.
+func rtuBitsExpected(request []byte) int {
+	count := int(binary.BigEndian.Uint16(request[3:]))
+	return 2 + (count+7)/8
+}
+
+// rtuRegistersExpected is the length of responses to register reads.
+func rtuRegistersExpected(request []byte) int {
+	count := int(binary.BigEndian.Uint16(request[3:]))
+	return 2 + 2*count
+}
+
+// rtuResponseLength returns the length of the response ADU starting with
+// adu, or the length needed to determine it if adu is too short.
+func rtuResponseLength(adu []byte) (int, error) {
+	if len(adu) < 2 {
+		return 2, nil
+	}
+	if adu[1]&0x80 != 0 {
+		return rtuExceptionSize, nil
+	}
+	framing, ok := rtuResponseFraming[adu[1]]
+	if !ok {
+		return 0, errRTULengthUnknown
+	}
+	length, err := framing.response(adu[1:])
+	// slave id and CRC
+	return 1 + length + 2, err
+}
+
+// rtuChecksumValid reports whether the CRC at the end of adu is valid.
+func rtuChecksumValid(adu []byte) bool {
+	if len(adu) < rtuMinSize {
+		return false
+	}
+	var crc crc
+	crc.reset().pushBytes(adu[:len(adu)-2])
+	return binary.LittleEndian.Uint16(adu[len(adu)-2:]) == crc.value()
+}
+
+// rtuResponseCandidate reports whether data may start the response of slaveID
+// to functionCode.
+func rtuResponseCandidate(data []byte, slaveID, functionCode byte) bool {
+	if len(data) == 0 || data[0] != slaveID {
+		return false
+	}
+	return len(data) < 2 || data[1] == functionCode || data[1] == functionCode|0x80
+}
+
+// findRTUFrame searches data for the response of slaveID to functionCode with
+// a valid CRC. Candidates failing the CRC are skipped, resynchronising on the
+// following bytes. If no frame is found, pending is the offset of the first
+// candidate still incomplete, or -1 if there is none, and err describes why
+// the received bytes were discarded.
+func findRTUFrame(data []byte, slaveID, functionCode byte) (frame []byte, pending int, err error) {
+	pending = -1
+	if len(data) > 0 {
+		err = errorf(ErrResponseMismatch, "modbus: discarded %d bytes not from slave id '%v' with function code '%v'", len(data), slaveID, functionCode)
+	}
+	for start := range data {
+		candidate := data[start:]
+		if !rtuResponseCandidate(candidate, slaveID, functionCode) {
+			continue
+		}
+		length, lengthErr := rtuResponseLength(candidate)
+		if lengthErr != nil && lengthErr != errRTULengthUnknown || length > rtuMaxSize {
+			continue
+		}
+		if lengthErr != nil || length > len(candidate) {
+			if pending < 0 {
+				pending = start
+			}
+			continue
+		}
+		if rtuChecksumValid(candidate[:length]) {
+			return candidate[:length], start, nil
+		}
+		var crc crc
+		crc.reset().pushBytes(candidate[:length-2])
+		err = &ChecksumError{Kind: "crc", Got: binary.LittleEndian.Uint16(candidate[length-2:]), Expected: crc.value()}
+	}
+	return nil, pending, err
+}
+
+// findRTUFrameAtGap searches data for a response of unknown length after a
+// silence on the line, taking all bytes of a candidate as its frame.
+func findRTUFrameAtGap(data []byte, slaveID, functionCode byte) []byte {
+	for start := range data {
+		candidate := data[start:]
+		if !rtuResponseCandidate(candidate, slaveID, functionCode) || len(candidate) > rtuMaxSize {
+			continue
+		}
+		if _, err := rtuResponseLength(candidate); err == errRTULengthUnknown && rtuChecksumValid(candidate) {
+			return candidate
+		}
+	}
+	return nil
+}
+
+// readRTUFrame reads from conn until the response of slaveID to functionCode
+// is received. Bytes not belonging to it are discarded. If gap is positive,
+// a silence of gap ends responses of unknown length, or fails the read if
+// only discarded bytes were received.
+func readRTUFrame(conn net.Conn, slaveID, functionCode byte, deadline time.Time, gap time.Duration) ([]byte, error) {
+	var data []byte
+	var received bool
+	var discardErr error
+	buf := make([]byte, rtuMaxSize)
+	for {
+		readDeadline := deadline
+		if gap > 0 && received {
+			if d := time.Now().Add(gap); deadline.IsZero() || d.Before(deadline) {
+				readDeadline = d
+			}
+		}
+		if err := conn.SetReadDeadline(readDeadline); err != nil {
+			return nil, err
+		}
+		n, err := conn.Read(buf)
+		data = append(data, buf[:n]...)
+		received = received || n > 0
+
+		frame, pending, findErr := findRTUFrame(data, slaveID, functionCode)
+		if frame != nil {
+			return frame, nil
+		}
+		if findErr != nil {
+			discardErr = findErr
+		}
+		if err != nil {
+			if !isTimeout(err) || readDeadline.Equal(deadline) {
+				return nil, err
+			}
+			if frame = findRTUFrameAtGap(data, slaveID, functionCode); frame != nil {
+				return frame, nil
+			}
+			if pending < 0 && discardErr != nil {
+				return nil, discardErr
+			}
+		}
+		if pending < 0 {
+			data = data[:0]
+		} else if pending > 0 {
+			data = append(data[:0], data[pending:]...)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package modbus
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func rtuFrame(t *testing.T, slaveID byte, pdu ...byte) []byte {
+	t.Helper()
+	adu, err := (&rtuPackager{SlaveID: slaveID}).Encode(&ProtocolDataUnit{FunctionCode: pdu[0], Data: pdu[1:]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return adu
+}
+
+func TestRTUResponseLength(t *testing.T) {
+	tests := []struct {
+		name   string
+		pdu    []byte
+		length int
+		ok     bool
+	}{
+		{"byte count", []byte{FuncCodeReadHoldingRegisters, 4, 0, 1, 0, 2}, 9, true},
+		{"fixed", []byte{FuncCodeWriteSingleRegister, 0, 1, 0, 2}, 8, true},
+		{"mask write", []byte{FuncCodeMaskWriteRegister, 0, 1, 0, 2, 0, 3}, 10, true},
+		{"exception", []byte{FuncCodeReadCoils | 0x80, 2}, 5, true},
+		{"fifo", []byte{FuncCodeReadFIFOQueue, 0, 6, 0, 2, 0, 1, 0, 2}, 12, true},
+		{"mei", []byte{FuncCodeReadDeviceIdentification, 0x0E, 1, 1, 0, 0, 2, 0, 2, 'a', 'b', 1, 1, 'c'}, 17, true},
+		{"other mei", []byte{FuncCodeReadDeviceIdentification, 0x0D, 0}, 3, false},
+		{"unknown", []byte{0x41, 1, 2}, 3, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adu := append([]byte{1}, tt.pdu...)
+			length, err := rtuResponseLength(adu)
+			if ok := err == nil; ok != tt.ok || (ok && length != tt.length) {
+				t.Fatalf("expected length %d (%v), got %d (%v)", tt.length, tt.ok, length, err)
+			}
+			if err != nil {
+				return
+			}
+			// Shorter prefixes never claim to be complete
+			for i := 0; i < len(adu); i++ {
+				if need, _ := rtuResponseLength(adu[:i]); need <= i {
+					t.Fatalf("prefix of %d bytes: expected more than %d bytes, got %d", i, i, need)
+				}
+			}
+		})
+	}
+}
+
+func TestFindRTUFrame(t *testing.T) {
+	response := rtuFrame(t, 1, FuncCodeReadHoldingRegisters, 2, 0xCA, 0xFE)
+	stale := rtuFrame(t, 1, FuncCodeReadCoils, 1, 1)
+	corrupt := append([]byte(nil), response...)
+	corrupt[4] ^= 0xFF
+
+	data := append(append(append([]byte{0x00, 0x01}, stale...), corrupt...), response...)
+	frame, _, _ := findRTUFrame(data, 1, FuncCodeReadHoldingRegisters)
+	if !bytes.Equal(frame, response) {
+		t.Fatalf("expected % x, got % x", response, frame)
+	}
+
+	frame, pending, _ := findRTUFrame(append([]byte{0x00, 0x02}, response[:4]...), 1, FuncCodeReadHoldingRegisters)
+	if frame != nil || pending != 2 {
+		t.Fatalf("expected incomplete frame at 2, got % x at %d", frame, pending)
+	}
+
+	frame, pending, err := findRTUFrame(stale, 1, FuncCodeReadHoldingRegisters)
+	if frame != nil || pending != -1 || !errors.Is(err, ErrResponseMismatch) {
+		t.Fatalf("expected no candidate, got % x at %d: %v", frame, pending, err)
+	}
+
+	frame, _, err = findRTUFrame(corrupt, 1, FuncCodeReadHoldingRegisters)
+	if frame != nil || !errors.Is(err, ErrChecksum) {
+		t.Fatalf("expected checksum error, got % x: %v", frame, err)
+	}
+}
+
+func TestReadRTUFrameGap(t *testing.T) {
+	client, device := net.Pipe()
+	defer client.Close()
+	defer device.Close()
+
+	// A user-defined function code of unknown response length, in pieces
+	response := rtuFrame(t, 1, 0x41, 1, 2, 3)
+	go func() {
+		device.Write([]byte{0x07})
+		device.Write(response[:3])
+		device.Write(response[3:])
+	}()
+
+	frame, err := readRTUFrame(client, 1, 0x41, time.Now().Add(time.Second), 20*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(frame, response) {
+		t.Fatalf("expected % x, got % x", response, frame)
+	}
+
+	// Discarded bytes fail the read after the gap
+	go device.Write(rtuFrame(t, 2, 0x41, 1))
+	if _, err = readRTUFrame(client, 1, 0x41, time.Now().Add(time.Second), 20*time.Millisecond); !errors.Is(err, ErrResponseMismatch) {
+		t.Fatalf("expected response mismatch, got %v", err)
+	}
+
+	start := time.Now()
+	_, err = readRTUFrame(client, 1, 0x41, time.Now().Add(50*time.Millisecond), 10*time.Millisecond)
+	if !isTimeout(err) {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected the deadline to end the read, took %v", elapsed)
+	}
+}
+
+func TestRTUOverTCPDiscardsLateResponse(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		request := make([]byte, 8)
+		for i := 0; ; i++ {
+			if _, err := io.ReadFull(conn, request); err != nil {
+				return
+			}
+			if i == 0 {
+				// answer after the client timed out
+				time.Sleep(80 * time.Millisecond)
+				conn.Write(rtuFrame(t, 1, FuncCodeReadHoldingRegisters, 2, 0xDE, 0xAD))
+				continue
+			}
+			conn.Write(rtuFrame(t, 1, FuncCodeReadHoldingRegisters, 2, 0xCA, 0xFE))
+		}
+	}()
+
+	handler := NewRTUOverTCPClientHandler(ln.Addr().String())
+	handler.SlaveID = 1
+	handler.Timeout = 50 * time.Millisecond
+	defer handler.Close()
+	client := NewClient(handler)
+
+	if _, err := client.ReadHoldingRegisters(context.Background(), 0, 1); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		results, err := client.ReadHoldingRegisters(context.Background(), 0, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(results, []byte{0xCA, 0xFE}) {
+			t.Fatalf("expected the late response to be discarded, got % x", results)
+		}
+		if handler.stale {
+			t.Fatal("expected no drain after a successful request")
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package modbus
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Size of the chunk buffer of RTUFrameReader
+const rtuFrameReaderChunks = 64
+
+// errRTUFrameReaderClosed is returned by ReadFrame after Close.
+var errRTUFrameReaderClosed = fmt.Errorf("modbus: frame reader: %w", net.ErrClosed)
+
+// rtuSilentIntervals returns the maximum silence between the characters of a
+// frame (t1.5) and the minimum silence between frames (t3.5) at baudRate.
+// Above 19200 baud and for unknown baud rates the spec fixes them to 750µs
+// and 1750µs.
+func rtuSilentIntervals(baudRate int) (charTimeout, frameTimeout time.Duration) {
+	if baudRate <= 0 || baudRate > 19200 {
+		return 750 * time.Microsecond, 1750 * time.Microsecond
+	}
+	return time.Duration(15000000/baudRate) * time.Microsecond, time.Duration(35000000/baudRate) * time.Microsecond
+}
+
+// rtuChunk is the outcome of a read from the line, with its time of arrival.
+type rtuChunk struct {
+	data []byte
+	at   time.Time
+	err  error
+}
+
+// RTUFrameReader reads RTU frames delimited by silence on the line, as
+// specified by MODBUS over Serial Line: a frame ends after a silence of t3.5,
+// and a silence above t1.5 within a frame makes it invalid. Since gaps are
+// measured when reads return, they are approximate; adapters buffering the
+// line, like USB converters with latency timers, need larger timeouts.
+//
+// RTUFrameReader reads the underlying reader in the background from the
+// first ReadFrame on, until the reader fails or Close is called. It can be
+// used by clients and servers alike.
+type RTUFrameReader struct {
+	// Maximum silence between the characters of a frame (t1.5)
+	CharTimeout time.Duration
+	// Silence ending a frame (t3.5)
+	FrameTimeout time.Duration
+
+	r io.Reader
+	// transmission time of a character
+	charTime time.Duration
+
+	start  sync.Once
+	chunks chan rtuChunk
+	stop   sync.Once
+	done   chan struct{}
+	mu     sync.Mutex
+	next   *rtuChunk
+}
+
+// NewRTUFrameReader allocates a RTUFrameReader reading from r, with the
+// timeouts derived from baudRate. A baudRate of zero selects the timeouts
+// fixed for high baud rates.
+func NewRTUFrameReader(r io.Reader, baudRate int) *RTUFrameReader {
+	reader := &RTUFrameReader{
+		r:      r,
+		chunks: make(chan rtuChunk, rtuFrameReaderChunks),
+		done:   make(chan struct{}),
+	}
+	reader.CharTimeout, reader.FrameTimeout = rtuSilentIntervals(baudRate)
+	if baudRate > 0 {
+		reader.charTime = time.Duration(10000000/baudRate) * time.Microsecond
+	}
+	return reader
+}
+
+// ReadFrame reads the next frame, waiting for its first character until ctx
+// is done. Once started, a frame is read to its end. Frames with an illegal
+// silence between characters fail with an error matching ErrFraming.
+func (r *RTUFrameReader) ReadFrame(ctx context.Context) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	select {
+	case <-r.done:
+		return nil, errRTUFrameReaderClosed
+	default:
+	}
+	r.start.Do(func() { go r.readLoop() })
+
+	var frame []byte
+	var last time.Time
+	var gapErr error
+	for {
+		chunk, ok := r.receive(ctx, frame == nil, last)
+		if !ok {
+			if frame == nil {
+				if err := ctx.Err(); err != context.DeadlineExceeded {
+					return nil, err
+				}
+				return nil, fmt.Errorf("failed to read from serial port within deadline: %w", context.DeadlineExceeded)
+			}
+			if gapErr != nil {
+				return nil, gapErr
+			}
+			return frame, nil
+		}
+		if chunk.err != nil {
+			return nil, chunk.err
+		}
+		if frame != nil {
+			// The last character of the chunk arrived after the silence and
+			// the transmission of the chunk.
+			gap := chunk.at.Sub(last) - time.Duration(len(chunk.data))*r.charTime
+			if gap > r.CharTimeout && gapErr == nil {
+				gapErr = errorf(ErrFraming, "modbus: silence of %v within frame exceeds %v", gap, r.CharTimeout)
+			}
+		}
+		frame = append(frame, chunk.data...)
+		last = chunk.at
+	}
+}
+
+// receive returns the next chunk, waiting until ctx is done for the first
+// chunk of a frame, or until the frame timeout after the last chunk. Chunks
+// that arrived in time but were not received yet are taken into account.
+func (r *RTUFrameReader) receive(ctx context.Context, first bool, last time.Time) (rtuChunk, bool) {
+	var end time.Time
+	if first {
+		end, _ = ctx.Deadline()
+	} else {
+		end = last.Add(r.FrameTimeout)
+	}
+	if r.next != nil {
+		chunk := *r.next
+		if !first && chunk.at.After(end) {
+			return rtuChunk{}, false
+		}
+		r.next = nil
+		return chunk, true
+	}
+
+	var expired <-chan time.Time
+	var done <-chan struct{}
+	if first {
+		done = ctx.Done()
+	} else {
+		timer := time.NewTimer(time.Until(end))
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case chunk := <-r.chunks:
+		if !first && chunk.at.After(end) {
+			// The chunk starts the next frame
+			r.next = &chunk
+			return rtuChunk{}, false
+		}
+		return chunk, true
+	case <-r.done:
+		return rtuChunk{err: errRTUFrameReaderClosed}, true
+	case <-done:
+	case <-expired:
+	}
+	select {
+	case chunk := <-r.chunks:
+		if !end.IsZero() && !chunk.at.After(end) {
+			return chunk, true
+		}
+		r.next = &chunk
+	default:
+	}
+	return rtuChunk{}, false
+}
+
+// Discard drops the characters received but not read yet.
+func (r *RTUFrameReader) Discard() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.next != nil && r.next.err == nil {
+		r.next = nil
+	}
+	for r.next == nil {
+		select {
+		case chunk := <-r.chunks:
+			if chunk.err != nil {
+				// keep the failure for the next read
+				r.next = &chunk
+			}
+		default:
+			return
+		}
+	}
+}
+
+// Close stops the background reads and fails the pending and later calls of
+// ReadFrame. It does not close the underlying reader: a read in progress
+// returns when the reader is closed or times out, and its data is dropped.
+func (r *RTUFrameReader) Close() error {
+	r.stop.Do(func() { close(r.done) })
+	return nil
+}
+
+// readLoop reads the underlying reader, stamping the chunks with their time
+// of arrival, until it fails or the frame reader is closed. Read timeouts of
+// the reader are ignored.
+func (r *RTUFrameReader) readLoop() {
+	buf := make([]byte, rtuMaxSize)
+	for {
+		select {
+		case <-r.done:
+			return
+		default:
+		}
+		n, err := r.r.Read(buf)
+		at := time.Now()
+		if isTimeout(err) {
+			err = nil
+		}
+		if n > 0 || err != nil {
+			select {
+			case r.chunks <- rtuChunk{data: append([]byte(nil), buf[:n]...), at: at, err: err}:
+			case <-r.done:
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package modbus
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRTUSilentIntervals(t *testing.T) {
+	tests := []struct {
+		baudRate                  int
+		charTimeout, frameTimeout time.Duration
+	}{
+		{9600, 1562 * time.Microsecond, 3645 * time.Microsecond},
+		{19200, 781 * time.Microsecond, 1822 * time.Microsecond},
+		{38400, 750 * time.Microsecond, 1750 * time.Microsecond},
+		{0, 750 * time.Microsecond, 1750 * time.Microsecond},
+	}
+	for _, tt := range tests {
+		charTimeout, frameTimeout := rtuSilentIntervals(tt.baudRate)
+		if charTimeout != tt.charTimeout || frameTimeout != tt.frameTimeout {
+			t.Errorf("%d baud: expected %v/%v, got %v/%v", tt.baudRate, tt.charTimeout, tt.frameTimeout, charTimeout, frameTimeout)
+		}
+	}
+}
+
+func TestRTUFrameReader(t *testing.T) {
+	line, device := net.Pipe()
+	defer line.Close()
+	defer device.Close()
+
+	reader := NewRTUFrameReader(line, 0)
+	// Generous timeouts for the scheduler
+	reader.CharTimeout = 20 * time.Millisecond
+	reader.FrameTimeout = 60 * time.Millisecond
+
+	first, second := []byte{1, 3, 2, 0xCA, 0xFE, 0x12, 0x34}, []byte{2, 6, 0, 1, 0, 2, 0x56, 0x78}
+	go func() {
+		device.Write(first[:3])
+		device.Write(first[3:])
+		time.Sleep(100 * time.Millisecond)
+		device.Write(second)
+		time.Sleep(100 * time.Millisecond)
+		// silence above t1.5 within a frame
+		device.Write(first[:3])
+		time.Sleep(40 * time.Millisecond)
+		device.Write(first[3:])
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for _, want := range [][]byte{first, second} {
+		frame, err := reader.ReadFrame(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(frame, want) {
+			t.Fatalf("expected frame % x, got % x", want, frame)
+		}
+	}
+	if _, err := reader.ReadFrame(ctx); !errors.Is(err, ErrFraming) {
+		t.Fatalf("expected framing error, got %v", err)
+	}
+	timeoutCtx, cancelTimeout := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelTimeout()
+	if _, err := reader.ReadFrame(timeoutCtx); !isTimeout(err) {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+	canceledCtx, cancelCanceled := context.WithCancel(context.Background())
+	cancelCanceled()
+	if _, err := reader.ReadFrame(canceledCtx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation, got %v", err)
+	}
+}
+
+func TestRTUFrameReaderClose(t *testing.T) {
+	line, device := io.Pipe()
+	defer device.Close()
+	reader := NewRTUFrameReader(line, 0)
+
+	// start the background read, then fill the chunk buffer until it blocks
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if _, err := reader.ReadFrame(ctx); !isTimeout(err) {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+	var written atomic.Int32
+	go func() {
+		for {
+			if _, err := device.Write([]byte{1}); err != nil {
+				return
+			}
+			written.Add(1)
+		}
+	}()
+	for written.Load() <= rtuFrameReaderChunks {
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := reader.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reader.ReadFrame(context.Background()); !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("expected closed error, got %v", err)
+	}
+	// the background read stopped instead of waiting for buffer space
+	reader.Discard()
+	time.Sleep(10 * time.Millisecond)
+	if n := written.Load(); n > rtuFrameReaderChunks+2 {
+		t.Fatalf("expected the reads to stop, got %d", n)
+	}
+}
+
+func TestRTUClientFrameTiming(t *testing.T) {
+	handler := NewRTUStreamClientHandler(streamResponder(t, 8, func(request []byte) []byte {
+		adu, _ := (&rtuPackager{SlaveID: request[0]}).Encode(&ProtocolDataUnit{FunctionCode: request[1], Data: []byte{2, 0xCA, 0xFE}})
+		return adu
+	}))
+	handler.SlaveID = 1
+	handler.FrameTiming = true
+	handler.FrameTimeout = 20 * time.Millisecond
+	handler.CharTimeout = 10 * time.Millisecond
+	defer handler.Close()
+	client := NewClient(handler)
+
+	for i := 0; i < 2; i++ {
+		results, err := client.ReadHoldingRegisters(context.Background(), 0, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(results, []byte{0xCA, 0xFE}) {
+			t.Fatalf("unexpected results % x", results)
+		}
+	}
+}
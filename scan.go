@@ -0,0 +1,189 @@
+package modbus
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Default timeout of the probe requests to a single unit id
+const scanTimeout = time.Second
+
+// ScannedDevice is a device found by a Scanner.
+type ScannedDevice struct {
+	UnitID byte
+	// Identification holds the identification objects, if the device
+	// supports Read Device Identification.
+	Identification *DeviceIdentification
+	// ServerID holds the Report Server ID data, if the device supports it
+	// but not Read Device Identification.
+	ServerID []byte
+	// ProbeException is the exception code the probe register was answered
+	// with, if the device supports neither identification. Zero if the
+	// register was read.
+	ProbeException byte
+	// Latency is the time taken by the device identification.
+	Latency time.Duration
+}
+
+// Scanner finds the devices behind a gateway or on a bus by probing unit
+// ids. A unit id answering a request, even with an exception, is a device;
+// it is identified by Read Device Identification, Report Server ID or, if it
+// supports neither, by reading a probe register. Unit ids not answering the
+// first request or answered with a gateway exception are skipped.
+//
+// Each handler probes one unit id at a time, the handlers probe concurrently.
+// Transports allowing it, e.g. TCP gateways, may be scanned faster by
+// passing several handlers to the same address.
+type Scanner struct {
+	// UnitIDs to probe, defaults to 1 to 247.
+	UnitIDs []byte
+	// Timeout of the probe requests to a single unit id. It replaces the
+	// Timeout of the handlers of this package during the scan, as every
+	// silent unit id waits for it. Other handlers only see it as the
+	// deadline of the request context.
+	Timeout time.Duration
+	// ProbeAddress is the holding register read from devices supporting
+	// neither identification.
+	ProbeAddress uint16
+
+	handlers []ClientHandler
+}
+
+// NewScanner allocates a Scanner probing unit ids 1 to 247 with handlers,
+// which must not be used otherwise during a scan.
+func NewScanner(handlers ...ClientHandler) *Scanner {
+	unitIDs := make([]byte, 0, 247)
+	for unitID := 1; unitID <= 247; unitID++ {
+		unitIDs = append(unitIDs, byte(unitID))
+	}
+	return &Scanner{
+		UnitIDs:  unitIDs,
+		Timeout:  scanTimeout,
+		handlers: handlers,
+	}
+}
+
+// Scan probes all unit ids and returns the devices found, ordered by unit id.
+// It stops at the first error other than a missing or garbled response, or
+// when ctx is done, returning the devices found so far with the error.
+func (s *Scanner) Scan(parent context.Context) ([]ScannedDevice, error) {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	unitIDs := make(chan byte)
+	go func() {
+		defer close(unitIDs)
+		for _, unitID := range s.UnitIDs {
+			select {
+			case unitIDs <- unitID:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var (
+		mu       sync.Mutex
+		devices  []ScannedDevice
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	for _, handler := range s.handlers {
+		wg.Add(1)
+		go func(handler ClientHandler) {
+			defer wg.Done()
+			if t, ok := handler.(timeoutSetter); ok && s.Timeout > 0 {
+				defer t.setTimeout(t.setTimeout(s.Timeout))
+			}
+			mb := &client{packager: handler, transporter: handler}
+			for unitID := range unitIDs {
+				if ctx.Err() != nil {
+					return
+				}
+				handler.SetSlave(unitID)
+				device, err := s.probe(ctx, mb, unitID)
+				mu.Lock()
+				if device != nil {
+					devices = append(devices, *device)
+				}
+				if err != nil && firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				mu.Unlock()
+			}
+		}(handler)
+	}
+	wg.Wait()
+	if firstErr == nil {
+		firstErr = parent.Err()
+	}
+
+	sort.Slice(devices, func(i, j int) bool { return devices[i].UnitID < devices[j].UnitID })
+	return devices, firstErr
+}
+
+// timeoutSetter is implemented by the transporters of this package and
+// replaces the timeout of their requests, returning the previous one.
+type timeoutSetter interface {
+	setTimeout(timeout time.Duration) (previous time.Duration)
+}
+
+// probe identifies the device at unitID. It returns nil if no device answers.
+func (s *Scanner) probe(scanCtx context.Context, mb *client, unitID byte) (*ScannedDevice, error) {
+	ctx := scanCtx
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+	device := &ScannedDevice{UnitID: unitID}
+	start := time.Now()
+
+	identification, err := mb.IdentifyDevice(ctx)
+	if answered, err := scanAnswered(scanCtx, err); !answered {
+		return nil, err
+	}
+	device.Latency = time.Since(start)
+	if err == nil {
+		device.Identification = identification
+		return device, nil
+	}
+
+	serverID, err := mb.ReportServerID(ctx)
+	if answered, err := scanAnswered(scanCtx, err); !answered {
+		return device, err
+	}
+	if err == nil {
+		device.ServerID = serverID
+		return device, nil
+	}
+
+	_, err = mb.ReadHoldingRegisters(ctx, s.ProbeAddress, 1)
+	if answered, err := scanAnswered(scanCtx, err); !answered {
+		return device, err
+	}
+	var mbErr *Error
+	if errors.As(err, &mbErr) {
+		device.ProbeException = mbErr.ExceptionCode
+	}
+	return device, nil
+}
+
+// scanAnswered reports whether a device answered a probe request failing
+// with err, and returns the error stopping the scan, if any.
+func scanAnswered(scanCtx context.Context, err error) (bool, error) {
+	switch {
+	case err == nil || IsDeviceException(err):
+		return true, nil
+	case scanCtx.Err() != nil:
+		return false, scanCtx.Err()
+	case IsGatewayException(err), isTimeout(err),
+		errors.Is(err, ErrChecksum), errors.Is(err, ErrFraming), errors.Is(err, ErrResponseMismatch):
+		return false, nil
+	}
+	return false, err
+}
@@ -0,0 +1,166 @@
+package modbus
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// scanHandler answers the requests to a unit id with its responder and times
+// out for unit ids without one.
+type scanHandler struct {
+	rtuPackager
+
+	responders map[byte]func(*ProtocolDataUnit) *ProtocolDataUnit
+	mu         *sync.Mutex
+	requests   *int
+}
+
+func (h *scanHandler) Send(_ context.Context, aduRequest []byte) ([]byte, error) {
+	h.mu.Lock()
+	*h.requests++
+	h.mu.Unlock()
+	respond, ok := h.responders[aduRequest[0]]
+	if !ok {
+		return nil, &timeoutError{}
+	}
+	return (&pduTransporter{respond: respond}).Send(context.Background(), aduRequest)
+}
+
+func (h *scanHandler) Connect(context.Context) error { return nil }
+func (h *scanHandler) Close() error                  { return nil }
+
+func TestScanner(t *testing.T) {
+	exception := func(request *ProtocolDataUnit) *ProtocolDataUnit {
+		return &ProtocolDataUnit{FunctionCode: request.FunctionCode | 0x80, Data: []byte{ExceptionCodeIllegalFunction}}
+	}
+	identified := identificationResponder(map[byte]string{ObjectIDVendorName: "grid-x", ObjectIDProductCode: "gx-1", ObjectIDMajorMinorRevision: "1.0"}, 0x01, true)
+	responders := map[byte]func(*ProtocolDataUnit) *ProtocolDataUnit{
+		3: identified,
+		7: func(request *ProtocolDataUnit) *ProtocolDataUnit {
+			if request.FunctionCode != FuncCodeReportServerID {
+				return exception(request)
+			}
+			return &ProtocolDataUnit{FunctionCode: request.FunctionCode, Data: []byte{3, 0x2A, 0xFF, 'x'}}
+		},
+		9: func(request *ProtocolDataUnit) *ProtocolDataUnit {
+			if request.FunctionCode != FuncCodeReadHoldingRegisters {
+				return exception(request)
+			}
+			return &ProtocolDataUnit{FunctionCode: request.FunctionCode, Data: []byte{2, 0, 1}}
+		},
+		11: exception,
+		12: func(request *ProtocolDataUnit) *ProtocolDataUnit {
+			return &ProtocolDataUnit{FunctionCode: request.FunctionCode | 0x80, Data: []byte{ExceptionCodeGatewayTargetDeviceFailedToRespond}}
+		},
+	}
+	var (
+		mu       sync.Mutex
+		requests int
+	)
+	handlers := []ClientHandler{
+		&scanHandler{responders: responders, mu: &mu, requests: &requests},
+		&scanHandler{responders: responders, mu: &mu, requests: &requests},
+	}
+	scanner := NewScanner(handlers...)
+	scanner.UnitIDs = []byte{1, 3, 7, 9, 11, 12}
+
+	devices, err := scanner.Scan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range devices {
+		devices[i].Latency = 0
+	}
+	expected := []ScannedDevice{
+		{UnitID: 3, Identification: &DeviceIdentification{
+			VendorName: "grid-x", ProductCode: "gx-1", MajorMinorRevision: "1.0",
+			ConformityLevel: 0x01, AccessLevel: ReadDeviceIDCodeBasic,
+		}},
+		{UnitID: 7, ServerID: []byte{0x2A, 0xFF, 'x'}},
+		{UnitID: 9},
+		{UnitID: 11, ProbeException: ExceptionCodeIllegalFunction},
+	}
+	if !reflect.DeepEqual(devices, expected) {
+		t.Errorf("expected %+v, got %+v", expected, devices)
+	}
+	// silent and gateway rejected unit ids are probed once, the others until
+	// identified: 1, 3, 12 once, 7 twice, 9 and 11 three times
+	if requests != 11 {
+		t.Errorf("expected 11 requests, got %d", requests)
+	}
+}
+
+func TestScannerStopsOnError(t *testing.T) {
+	errDial := errors.New("dial failed")
+	handler := &scriptedHandler{errs: []error{errDial}}
+	scanner := NewScanner(handler)
+
+	devices, err := scanner.Scan(context.Background())
+	if !errors.Is(err, errDial) {
+		t.Errorf("expected %v, got %v", errDial, err)
+	}
+	if len(devices) != 0 || len(handler.sends) != 1 {
+		t.Errorf("expected the scan to stop after one request, got %d requests and %+v", len(handler.sends), devices)
+	}
+}
+
+func TestScannerCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	handler := &scriptedHandler{}
+	scanner := NewScanner(handler)
+
+	if _, err := scanner.Scan(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+	if len(handler.sends) != 0 {
+		t.Errorf("expected no requests, got %d", len(handler.sends))
+	}
+}
+
+func TestScannerTimeout(t *testing.T) {
+	// a gateway accepting connections but never answering
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			go io.Copy(io.Discard, conn)
+		}
+	}()
+
+	handler := NewTCPClientHandler(ln.Addr().String())
+	defer handler.Close()
+	scanner := NewScanner(handler)
+	scanner.UnitIDs = []byte{1, 2, 3}
+	scanner.Timeout = 50 * time.Millisecond
+
+	start := time.Now()
+	devices, err := scanner.Scan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) != 0 {
+		t.Errorf("expected no devices, got %+v", devices)
+	}
+	// the handler timeout of 10s is replaced by the scanner timeout
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the scan to take about 150ms, took %v", elapsed)
+	}
+	if handler.Timeout != tcpTimeout {
+		t.Errorf("expected the handler timeout to be restored, got %v", handler.Timeout)
+	}
+}
@@ -5,10 +5,12 @@
 package modbus
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"sync"
 	"time"
 
@@ -23,12 +25,53 @@ const (
 	serialReconnectRetryInterval = 10 * time.Millisecond
 )
 
+// SerialOpenFunc opens a serial port with the given configuration.
+type SerialOpenFunc func(c *serial.Config) (io.ReadWriteCloser, error)
+
+func defaultSerialOpenFunc(c *serial.Config) (io.ReadWriteCloser, error) {
+	return serial.Open(c)
+}
+
+// EchoError is returned if the echo of a request differs from the request,
+// e.g. because of a collision on the bus.
+type EchoError struct {
+	Request []byte
+	Echo    []byte
+}
+
+// Error implements the error interface.
+func (e *EchoError) Error() string {
+	return fmt.Sprintf("modbus: echo '% x' differs from request '% x', bus collision", e.Echo, e.Request)
+}
+
+// Is reports whether target is ErrFraming.
+func (e *EchoError) Is(target error) bool {
+	return target == ErrFraming
+}
+
+// verifyEcho returns an *EchoError if echo differs from request.
+func verifyEcho(request, echo []byte) error {
+	if !bytes.Equal(request, echo) {
+		return &EchoError{Request: request, Echo: echo}
+	}
+	return nil
+}
+
 // serialPort has configuration and I/O controller.
 type serialPort struct {
 	// Serial port configuration.
 	serial.Config
+	// Open opens the serial port, e.g. to wrap it for tests.
+	// If nil, the port is opened using the serial package.
+	Open SerialOpenFunc
+	// openStream, if set, opens a generic stream instead of a serial port.
+	openStream StreamOpenFunc
 
 	Logger Logger
+	// Structured logger, takes precedence over Logger
+	StructuredLogger *slog.Logger
+	// Observer receives transport events, e.g. Stats
+	Observer Observer
 	// IdleTimeout is the duration to close the connection when no activity.
 	IdleTimeout time.Duration
 	// Silent period after successful connection
@@ -38,12 +81,20 @@ type serialPort struct {
 	// Interval between reconnect attempts while spending the link recovery budget.
 	// Zero or negative values fall back to the default retry interval.
 	ReconnectRetryInterval time.Duration
+	// EchoSuppression reads back and verifies the echo of every request
+	// before reading the response, for RS485 adapters receiving what they
+	// transmit. A differing echo fails the request with an *EchoError.
+	EchoSuppression bool
 
 	mu sync.Mutex
 	// port is platform-dependent data structure for serial port.
-	port         io.ReadWriteCloser
+	port io.ReadWriteCloser
+	// generation counts the ports opened, telling when port changed
+	generation   uint64
 	lastActivity time.Time
 	closeTimer   *time.Timer
+
+	printfLogger printfLogger
 }
 
 func (mb *serialPort) Connect(ctx context.Context) (err error) {
@@ -62,11 +113,12 @@ func (mb *serialPort) connect(ctx context.Context) error {
 	default:
 	}
 	if mb.port == nil {
-		port, err := serial.Open(&mb.Config)
+		port, err := mb.open(ctx)
 		if err != nil {
 			return fmt.Errorf("could not open %s: %w", mb.Address, err)
 		}
 		mb.port = port
+		mb.generation++
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
@@ -76,6 +128,40 @@ func (mb *serialPort) connect(ctx context.Context) error {
 	return nil
 }
 
+// open opens the serial port, or the stream if openStream is set.
+func (mb *serialPort) open(ctx context.Context) (io.ReadWriteCloser, error) {
+	if mb.openStream != nil {
+		stream, err := mb.openStream(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return newStreamConn(stream), nil
+	}
+	open := mb.Open
+	if open == nil {
+		open = defaultSerialOpenFunc
+	}
+	return open(&mb.Config)
+}
+
+// setReadDeadline sets the read deadline of ports supporting it, so that
+// blocking reads end at the deadline. Caller must hold the mutex.
+func (mb *serialPort) setReadDeadline(deadline time.Time) error {
+	if port, ok := mb.port.(readDeadliner); ok {
+		return port.SetReadDeadline(deadline)
+	}
+	return nil
+}
+
+// readEcho reads the echo of request and verifies it. Caller must hold the mutex.
+func (mb *serialPort) readEcho(request []byte) error {
+	echo := make([]byte, len(request))
+	if _, err := io.ReadFull(mb.port, echo); err != nil {
+		return fmt.Errorf("modbus: read echo: %w", err)
+	}
+	return verifyEcho(request, echo)
+}
+
 func (mb *serialPort) Close() (err error) {
 	mb.mu.Lock()
 	defer mb.mu.Unlock()
@@ -92,10 +178,27 @@ func (mb *serialPort) close() (err error) {
 	return
 }
 
-func (mb *serialPort) logf(format string, v ...interface{}) {
-	if mb.Logger != nil {
-		mb.Logger.Printf(format, v...)
+func (mb *serialPort) logger() *slog.Logger {
+	return mb.printfLogger.of(mb.StructuredLogger, mb.Logger)
+}
+
+func (mb *serialPort) observer() Observer {
+	if mb.Observer != nil {
+		return mb.Observer
 	}
+	return nopObserver{}
+}
+
+func (mb *serialPort) transportAddress() string {
+	return mb.Address
+}
+
+func (mb *serialPort) setTimeout(timeout time.Duration) time.Duration {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	previous := mb.Timeout
+	mb.Timeout = timeout
+	return previous
 }
 
 func (mb *serialPort) shouldRecover(err error) bool {
@@ -104,14 +207,15 @@ func (mb *serialPort) shouldRecover(err error) bool {
 
 func (mb *serialPort) reconnect(ctx context.Context, err error, linkRecoveryDeadline time.Time) error {
 	if mb.LinkRecoveryTimeout == 0 || time.Until(linkRecoveryDeadline) < 0 {
-		return fmt.Errorf("modbus: link recovery timeout reached: %w", err)
+		return errorf(ErrConnectionLost, "modbus: link recovery timeout reached: %w", err)
 	}
 
-	mb.logf("modbus: connection reset, reconnecting")
+	mb.logger().Warn("modbus: connection reset, reconnecting", errorAttrs(err, LogActionReconnect)...)
+	mb.observer().Reconnect()
 	recoveryErr := err
 	if cerr := mb.close(); cerr != nil {
 		recoveryErr = errors.Join(recoveryErr, cerr)
-		mb.logf("modbus: error closing connection: %v", cerr)
+		mb.logger().Warn("modbus: error closing connection", errorAttrs(cerr, "")...)
 	}
 
 	deadlineTimer := time.NewTimer(time.Until(linkRecoveryDeadline))
@@ -124,14 +228,14 @@ func (mb *serialPort) reconnect(ctx context.Context, err error, linkRecoveryDead
 			return nil
 		} else {
 			recoveryErr = errors.Join(recoveryErr, cerr)
-			mb.logf("modbus: error reconnecting: %v", cerr)
+			mb.logger().Warn("modbus: error reconnecting", errorAttrs(cerr, LogActionRetry)...)
 		}
 
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 		case <-deadlineTimer.C:
-			return fmt.Errorf("modbus: link recovery timeout reached: %w", recoveryErr)
+			return errorf(ErrConnectionLost, "modbus: link recovery timeout reached: %w", recoveryErr)
 		case <-retryTicker.C:
 		}
 	}
@@ -165,7 +269,7 @@ func (mb *serialPort) closeIdle() {
 	}
 
 	if idle := time.Since(mb.lastActivity); idle >= mb.IdleTimeout {
-		mb.logf("modbus: closing connection due to idle timeout: %v", idle)
+		mb.logger().Debug("modbus: closing connection due to idle timeout", slog.Duration("idle", idle))
 		_ = mb.close()
 	}
 }
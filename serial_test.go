@@ -6,6 +6,7 @@ import (
 	"errors"
 	"io"
 	"log"
+	"net"
 	"os"
 	"path/filepath"
 	"strings"
@@ -180,3 +181,85 @@ func TestSerialReconnectHotPlug_EventuallySucceedsWithinRecoveryWindow_PTY(t *te
 		t.Fatalf("expected reconnect to log failed reopen attempts before success, got %d logs: %q", count, logs.String())
 	}
 }
+
+func TestSerialEchoSuppression(t *testing.T) {
+	response := &ProtocolDataUnit{FunctionCode: FuncCodeReadHoldingRegisters, Data: []byte{2, 0xCA, 0xFE}}
+	// echo answers with the echo of the request, corrupted if collide is set, and the response
+	echo := func(collide bool, encode func(request []byte) []byte) func([]byte) []byte {
+		return func(request []byte) []byte {
+			echoed := append([]byte(nil), request...)
+			if collide {
+				echoed[2] ^= 0x10
+			}
+			return append(echoed, encode(request)...)
+		}
+	}
+	rtu := func(request []byte) []byte {
+		adu, _ := (&rtuPackager{SlaveID: request[0]}).Encode(response)
+		return adu
+	}
+	ascii := func([]byte) []byte {
+		adu, _ := (&asciiPackager{SlaveID: 1}).Encode(response)
+		return adu
+	}
+
+	tests := []struct {
+		name    string
+		handler func(collide bool) ClientHandler
+	}{
+		{"RTU", func(collide bool) ClientHandler {
+			handler := NewRTUStreamClientHandler(streamResponder(t, 8, echo(collide, rtu)))
+			handler.EchoSuppression = true
+			return handler
+		}},
+		{"RTU frame timing", func(collide bool) ClientHandler {
+			handler := NewRTUStreamClientHandler(func(context.Context) (io.ReadWriteCloser, error) {
+				line, device := net.Pipe()
+				t.Cleanup(func() { device.Close() })
+				go func() {
+					request := make([]byte, 8)
+					if _, err := io.ReadFull(device, request); err != nil {
+						return
+					}
+					frames := echo(collide, rtu)(request)
+					device.Write(frames[:8])
+					// silence between the echo and the response
+					time.Sleep(50 * time.Millisecond)
+					device.Write(frames[8:])
+				}()
+				return plainStream{line}, nil
+			})
+			handler.EchoSuppression = true
+			handler.FrameTiming = true
+			handler.FrameTimeout = 20 * time.Millisecond
+			handler.CharTimeout = 10 * time.Millisecond
+			return handler
+		}},
+		{"ASCII", func(collide bool) ClientHandler {
+			handler := NewASCIIStreamClientHandler(streamResponder(t, 0, echo(collide, ascii)))
+			handler.EchoSuppression = true
+			return handler
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := tt.handler(false)
+			handler.SetSlave(1)
+			results, err := NewClient(handler).ReadHoldingRegisters(context.Background(), 0, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(results, []byte{0xCA, 0xFE}) {
+				t.Fatalf("unexpected results % x", results)
+			}
+
+			handler = tt.handler(true)
+			handler.SetSlave(1)
+			_, err = NewClient(handler).ReadHoldingRegisters(context.Background(), 0, 1)
+			var echoErr *EchoError
+			if !errors.As(err, &echoErr) || !errors.Is(err, ErrFraming) {
+				t.Fatalf("expected echo error, got %v", err)
+			}
+		})
+	}
+}
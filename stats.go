@@ -0,0 +1,194 @@
+package modbus
+
+import (
+	"sync"
+	"time"
+)
+
+// Observer receives the transport events of a handler. It allows to plug in
+// metrics systems such as Prometheus or OpenTelemetry without this package
+// depending on them. Implementations must be safe for concurrent use and
+// should not block.
+type Observer interface {
+	// RequestSent is called after a request of n bytes has been written.
+	RequestSent(n int)
+	// ResponseReceived is called after a response of n bytes has been read.
+	// rtt is the time elapsed since the request has been written.
+	ResponseReceived(n int, rtt time.Duration)
+	// ExceptionReceived is called for every modbus exception response.
+	ExceptionReceived(functionCode, exceptionCode byte)
+	// Timeout is called if no complete response arrived in time.
+	Timeout()
+	// ChecksumError is called if a response fails the CRC or LRC check.
+	ChecksumError()
+	// TransactionIDMismatch is called if a response with an unexpected
+	// transaction id is read.
+	TransactionIDMismatch()
+	// Reconnect is called if the connection is closed and reopened to
+	// recover from an error.
+	Reconnect()
+}
+
+// nopObserver is used by handlers without an Observer.
+type nopObserver struct{}
+
+func (nopObserver) RequestSent(int)                     {}
+func (nopObserver) ResponseReceived(int, time.Duration) {}
+func (nopObserver) ExceptionReceived(byte, byte)        {}
+func (nopObserver) Timeout()                            {}
+func (nopObserver) ChecksumError()                      {}
+func (nopObserver) TransactionIDMismatch()              {}
+func (nopObserver) Reconnect()                          {}
+
+// observed is implemented by the transporters of this package and gives the
+// client access to the Observer of the handler.
+type observed interface {
+	observer() Observer
+}
+
+// DefaultLatencyBuckets are the upper bounds of the round-trip latency
+// histogram used by NewStats.
+var DefaultLatencyBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// Stats is an Observer that counts the transport events of a handler.
+//
+//	stats := modbus.NewStats()
+//	handler.Observer = stats
+//	...
+//	snapshot := stats.Snapshot()
+type Stats struct {
+	mu       sync.Mutex
+	snapshot StatsSnapshot
+}
+
+// StatsSnapshot is a point-in-time copy of the counters of Stats.
+type StatsSnapshot struct {
+	RequestsSent            uint64
+	ResponsesReceived       uint64
+	Timeouts                uint64
+	ChecksumErrors          uint64
+	TransactionIDMismatches uint64
+	Reconnects              uint64
+	BytesSent               uint64
+	BytesReceived           uint64
+	// Exceptions counts exception responses by exception code.
+	Exceptions map[byte]uint64
+	// Latency is the histogram of request round-trip times.
+	Latency LatencyHistogram
+}
+
+// LatencyHistogram is a histogram of round-trip times.
+type LatencyHistogram struct {
+	// Bounds are the inclusive upper bounds of the buckets.
+	Bounds []time.Duration
+	// Counts holds the number of observations per bucket. It has one more
+	// element than Bounds counting the observations above the last bound.
+	Counts []uint64
+	// Count is the total number of observations.
+	Count uint64
+	// Sum is the sum of all observations.
+	Sum time.Duration
+}
+
+// NewStats allocates a Stats observer using DefaultLatencyBuckets.
+func NewStats() *Stats {
+	return NewStatsWithBuckets(DefaultLatencyBuckets)
+}
+
+// NewStatsWithBuckets allocates a Stats observer using the given ascending
+// upper bounds for the latency histogram.
+func NewStatsWithBuckets(bounds []time.Duration) *Stats {
+	s := &Stats{}
+	s.snapshot.Exceptions = make(map[byte]uint64)
+	s.snapshot.Latency.Bounds = append([]time.Duration(nil), bounds...)
+	s.snapshot.Latency.Counts = make([]uint64, len(bounds)+1)
+	return s
+}
+
+// Snapshot returns a copy of the current counters.
+func (s *Stats) Snapshot() StatsSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := s.snapshot
+	snapshot.Exceptions = make(map[byte]uint64, len(s.snapshot.Exceptions))
+	for code, n := range s.snapshot.Exceptions {
+		snapshot.Exceptions[code] = n
+	}
+	snapshot.Latency.Bounds = append([]time.Duration(nil), s.snapshot.Latency.Bounds...)
+	snapshot.Latency.Counts = append([]uint64(nil), s.snapshot.Latency.Counts...)
+	return snapshot
+}
+
+// RequestSent implements Observer.
+func (s *Stats) RequestSent(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshot.RequestsSent++
+	s.snapshot.BytesSent += uint64(n)
+}
+
+// ResponseReceived implements Observer.
+func (s *Stats) ResponseReceived(n int, rtt time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshot.ResponsesReceived++
+	s.snapshot.BytesReceived += uint64(n)
+
+	h := &s.snapshot.Latency
+	i := 0
+	for i < len(h.Bounds) && rtt > h.Bounds[i] {
+		i++
+	}
+	h.Counts[i]++
+	h.Count++
+	h.Sum += rtt
+}
+
+// ExceptionReceived implements Observer.
+func (s *Stats) ExceptionReceived(_, exceptionCode byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshot.Exceptions[exceptionCode]++
+}
+
+// Timeout implements Observer.
+func (s *Stats) Timeout() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshot.Timeouts++
+}
+
+// ChecksumError implements Observer.
+func (s *Stats) ChecksumError() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshot.ChecksumErrors++
+}
+
+// TransactionIDMismatch implements Observer.
+func (s *Stats) TransactionIDMismatch() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshot.TransactionIDMismatches++
+}
+
+// Reconnect implements Observer.
+func (s *Stats) Reconnect() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshot.Reconnects++
+}
@@ -0,0 +1,179 @@
+package modbus
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStatsLatencyHistogram(t *testing.T) {
+	stats := NewStatsWithBuckets([]time.Duration{10 * time.Millisecond, 100 * time.Millisecond})
+	stats.ResponseReceived(8, 5*time.Millisecond)
+	stats.ResponseReceived(8, 10*time.Millisecond)
+	stats.ResponseReceived(8, 50*time.Millisecond)
+	stats.ResponseReceived(8, time.Second)
+
+	snapshot := stats.Snapshot()
+	if want := []uint64{2, 1, 1}; !reflect.DeepEqual(snapshot.Latency.Counts, want) {
+		t.Fatalf("expected bucket counts %v, got %v", want, snapshot.Latency.Counts)
+	}
+	if snapshot.Latency.Count != 4 || snapshot.Latency.Sum != 1065*time.Millisecond {
+		t.Fatalf("unexpected count %v or sum %v", snapshot.Latency.Count, snapshot.Latency.Sum)
+	}
+	if snapshot.ResponsesReceived != 4 || snapshot.BytesReceived != 32 {
+		t.Fatalf("unexpected responses %v or bytes %v", snapshot.ResponsesReceived, snapshot.BytesReceived)
+	}
+
+	// a snapshot must not change with later observations
+	stats.ResponseReceived(8, time.Millisecond)
+	stats.ExceptionReceived(FuncCodeReadCoils, ExceptionCodeServerDeviceBusy)
+	if snapshot.Latency.Counts[0] != 2 || len(snapshot.Exceptions) != 0 {
+		t.Fatalf("snapshot was modified: %+v", snapshot)
+	}
+}
+
+func TestStatsTCPTransporter(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		_, _ = io.Copy(conn, conn)
+	}()
+	stats := NewStats()
+	client := &tcpTransporter{
+		Address:  ln.Addr().String(),
+		Timeout:  1 * time.Second,
+		Dial:     defaultDialFunc(1 * time.Second),
+		Observer: stats,
+	}
+	defer client.Close()
+
+	req := []byte{0, 1, 0, 2, 0, 2, 1, 2}
+	if _, err := client.Send(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	snapshot := stats.Snapshot()
+	if snapshot.RequestsSent != 1 || snapshot.BytesSent != uint64(len(req)) {
+		t.Fatalf("unexpected requests %v or bytes sent %v", snapshot.RequestsSent, snapshot.BytesSent)
+	}
+	if snapshot.ResponsesReceived != 1 || snapshot.BytesReceived != uint64(len(req)) {
+		t.Fatalf("unexpected responses %v or bytes received %v", snapshot.ResponsesReceived, snapshot.BytesReceived)
+	}
+	if snapshot.Latency.Count != 1 {
+		t.Fatalf("expected one latency observation, got %v", snapshot.Latency.Count)
+	}
+}
+
+func TestStatsTransactionIDMismatch(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		request := make([]byte, tcpHeaderSize+5)
+		if _, err := io.ReadFull(conn, request); err != nil {
+			return
+		}
+		response := append(request[:tcpHeaderSize:tcpHeaderSize], request[tcpHeaderSize], 2, 0xCA, 0xFE)
+		binary.BigEndian.PutUint16(response, binary.BigEndian.Uint16(request)+1)
+		binary.BigEndian.PutUint16(response[4:], 5)
+		conn.Write(response)
+	}()
+	tcpStats := NewStats()
+	tcpHandler := NewTCPClientHandler(ln.Addr().String())
+	tcpHandler.Observer = tcpStats
+	defer tcpHandler.Close()
+
+	var mismatch *TransactionIDMismatchError
+	if _, err := NewClient(tcpHandler).ReadHoldingRegisters(context.Background(), 0, 1); !errors.As(err, &mismatch) {
+		t.Fatalf("expected transaction id mismatch, got %v", err)
+	}
+	if got := tcpStats.Snapshot().TransactionIDMismatches; got != 1 {
+		t.Fatalf("TCP: expected 1 transaction id mismatch, got %d", got)
+	}
+
+	addr, _ := udpResponder(t, func(_ int, request []byte) [][]byte {
+		response := append(request[:tcpHeaderSize:tcpHeaderSize], request[tcpHeaderSize], 2, 0xCA, 0xFE)
+		binary.BigEndian.PutUint16(response[4:], 5)
+		stray := append([]byte(nil), response...)
+		binary.BigEndian.PutUint16(stray, binary.BigEndian.Uint16(request)+1)
+		return [][]byte{stray, response}
+	})
+	udpStats := NewStats()
+	udpHandler := NewTCPOverUDPClientHandler(addr)
+	udpHandler.Observer = udpStats
+	defer udpHandler.Close()
+
+	if _, err := NewClient(udpHandler).ReadHoldingRegisters(context.Background(), 0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := udpStats.Snapshot().TransactionIDMismatches; got != 1 {
+		t.Fatalf("UDP: expected 1 transaction id mismatch, got %d", got)
+	}
+}
+
+// observedTransporter is a pduTransporter with an Observer that optionally
+// corrupts the CRC of every response.
+type observedTransporter struct {
+	pduTransporter
+	stats   *Stats
+	corrupt bool
+}
+
+func (tr *observedTransporter) Send(ctx context.Context, aduRequest []byte) ([]byte, error) {
+	aduResponse, err := tr.pduTransporter.Send(ctx, aduRequest)
+	if err == nil && tr.corrupt {
+		aduResponse[len(aduResponse)-1] ^= 0xFF
+	}
+	return aduResponse, err
+}
+
+func (tr *observedTransporter) observer() Observer {
+	return tr.stats
+}
+
+func TestStatsClientErrors(t *testing.T) {
+	tr := &observedTransporter{stats: NewStats()}
+	tr.respond = func(request *ProtocolDataUnit) *ProtocolDataUnit {
+		return &ProtocolDataUnit{FunctionCode: request.FunctionCode | 0x80, Data: []byte{ExceptionCodeServerDeviceBusy}}
+	}
+	client := NewClient2(&rtuPackager{SlaveID: 1}, tr)
+	ctx := context.Background()
+
+	var mbErr *Error
+	if _, err := client.ReadCoils(ctx, 0, 1); !errors.As(err, &mbErr) {
+		t.Fatalf("expected exception, got %v", err)
+	}
+	tr.corrupt = true
+	if _, err := client.ReadCoils(ctx, 0, 1); err == nil {
+		t.Fatal("expected checksum error")
+	}
+
+	snapshot := tr.stats.Snapshot()
+	if want := map[byte]uint64{ExceptionCodeServerDeviceBusy: 1}; !reflect.DeepEqual(snapshot.Exceptions, want) {
+		t.Fatalf("expected exceptions %v, got %v", want, snapshot.Exceptions)
+	}
+	if snapshot.ChecksumErrors != 1 {
+		t.Fatalf("expected one checksum error, got %v", snapshot.ChecksumErrors)
+	}
+}
@@ -0,0 +1,188 @@
+package modbus
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// StreamOpenFunc opens a stream to a device, e.g. a USB CDC device of a vendor
+// SDK, a Bluetooth SPP socket or a Unix domain socket. If the returned stream
+// implements SetReadDeadline, like net.Conn and os.File do, timeouts use it.
+// Otherwise they are emulated by reading in the background.
+type StreamOpenFunc func(ctx context.Context) (io.ReadWriteCloser, error)
+
+// NewRTUStreamClientHandler allocates a RTUClientHandler reading and writing
+// RTU frames on streams opened by open. Of the serial configuration only
+// Timeout is used, and BaudRate for the inter-frame delay if set.
+func NewRTUStreamClientHandler(open StreamOpenFunc) *RTUClientHandler {
+	handler := NewRTUClientHandler("")
+	handler.openStream = open
+	return handler
+}
+
+// NewASCIIStreamClientHandler allocates an ASCIIClientHandler reading and
+// writing ASCII frames on streams opened by open. Of the serial configuration
+// only Timeout is used.
+func NewASCIIStreamClientHandler(open StreamOpenFunc) *ASCIIClientHandler {
+	handler := NewASCIIClientHandler("")
+	handler.openStream = open
+	return handler
+}
+
+// NewTCPStreamClientHandler allocates a TCPClientHandler reading and writing
+// MBAP frames on streams opened by open.
+func NewTCPStreamClientHandler(open StreamOpenFunc) *TCPClientHandler {
+	return NewTCPClientHandler("", WithDialer(func(ctx context.Context, _, _ string) (net.Conn, error) {
+		stream, err := open(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return newStreamConn(stream), nil
+	}))
+}
+
+// readDeadliner is implemented by streams supporting read deadlines.
+type readDeadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+// writeDeadliner is implemented by streams supporting write deadlines.
+type writeDeadliner interface {
+	SetWriteDeadline(t time.Time) error
+}
+
+// streamAddr is the address of a stream without network address.
+type streamAddr struct{}
+
+func (streamAddr) Network() string { return "stream" }
+func (streamAddr) String() string  { return "stream" }
+
+// streamResult is the outcome of a background read.
+type streamResult struct {
+	data []byte
+	err  error
+}
+
+// streamConn adapts a stream to net.Conn. Read deadlines are passed on to
+// the stream if it supports them, otherwise they are emulated by reading in
+// a background goroutine.
+type streamConn struct {
+	stream io.ReadWriteCloser
+
+	deadlineMu   sync.Mutex
+	readDeadline time.Time
+
+	mu        sync.Mutex
+	started   bool
+	results   chan streamResult
+	closed    chan struct{}
+	pending   []byte
+	err       error
+	closeOnce sync.Once
+}
+
+func newStreamConn(stream io.ReadWriteCloser) *streamConn {
+	return &streamConn{
+		stream:  stream,
+		results: make(chan streamResult),
+		closed:  make(chan struct{}),
+	}
+}
+
+func (c *streamConn) Read(b []byte) (int, error) {
+	if _, ok := c.stream.(readDeadliner); ok {
+		return c.stream.Read(b)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.pending) == 0 && c.err == nil {
+		if !c.started {
+			c.started = true
+			go c.readLoop()
+		}
+		c.deadlineMu.Lock()
+		deadline := c.readDeadline
+		c.deadlineMu.Unlock()
+		var expired <-chan time.Time
+		if !deadline.IsZero() {
+			timer := time.NewTimer(time.Until(deadline))
+			defer timer.Stop()
+			expired = timer.C
+		}
+		select {
+		case result := <-c.results:
+			c.pending, c.err = result.data, result.err
+		case <-expired:
+			return 0, os.ErrDeadlineExceeded
+		case <-c.closed:
+			return 0, net.ErrClosed
+		}
+	}
+	if len(c.pending) == 0 {
+		return 0, c.err
+	}
+	n := copy(b, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// readLoop reads the stream in the background until it fails or the
+// connection is closed.
+func (c *streamConn) readLoop() {
+	buf := make([]byte, udpMaxSize)
+	for {
+		n, err := c.stream.Read(buf)
+		result := streamResult{data: append([]byte(nil), buf[:n]...), err: err}
+		select {
+		case c.results <- result:
+		case <-c.closed:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (c *streamConn) Write(b []byte) (int, error) {
+	return c.stream.Write(b)
+}
+
+func (c *streamConn) Close() error {
+	err := c.stream.Close()
+	c.closeOnce.Do(func() { close(c.closed) })
+	return err
+}
+
+func (c *streamConn) LocalAddr() net.Addr  { return streamAddr{} }
+func (c *streamConn) RemoteAddr() net.Addr { return streamAddr{} }
+
+func (c *streamConn) SetDeadline(t time.Time) error {
+	if err := c.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.SetWriteDeadline(t)
+}
+
+func (c *streamConn) SetReadDeadline(t time.Time) error {
+	if d, ok := c.stream.(readDeadliner); ok {
+		return d.SetReadDeadline(t)
+	}
+	// Set concurrently with a blocked Read, the deadline applies to the next one.
+	c.deadlineMu.Lock()
+	defer c.deadlineMu.Unlock()
+	c.readDeadline = t
+	return nil
+}
+
+func (c *streamConn) SetWriteDeadline(t time.Time) error {
+	if d, ok := c.stream.(writeDeadliner); ok {
+		return d.SetWriteDeadline(t)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package modbus
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// plainStream hides the deadline methods of a connection.
+type plainStream struct {
+	io.ReadWriteCloser
+}
+
+// streamResponder returns an opener of in-memory streams. The device side
+// reads requests of the given size, or up to a line feed if size is zero,
+// and writes the ADU returned by respond. A nil ADU is not answered.
+func streamResponder(t *testing.T, size int, respond func(request []byte) []byte) StreamOpenFunc {
+	t.Helper()
+	return func(ctx context.Context) (io.ReadWriteCloser, error) {
+		client, device := net.Pipe()
+		t.Cleanup(func() { device.Close() })
+		go func() {
+			r := bufio.NewReader(device)
+			for {
+				var request []byte
+				var err error
+				if size > 0 {
+					request = make([]byte, size)
+					_, err = io.ReadFull(r, request)
+				} else {
+					request, err = r.ReadBytes('\n')
+				}
+				if err != nil {
+					return
+				}
+				if response := respond(request); response != nil {
+					device.Write(response)
+				}
+			}
+		}()
+		return plainStream{client}, nil
+	}
+}
+
+func TestStreamClientHandlers(t *testing.T) {
+	response := &ProtocolDataUnit{FunctionCode: FuncCodeReadHoldingRegisters, Data: []byte{2, 0xCA, 0xFE}}
+	tests := []struct {
+		name    string
+		handler func(t *testing.T) ClientHandler
+	}{
+		{"RTU", func(t *testing.T) ClientHandler {
+			handler := NewRTUStreamClientHandler(streamResponder(t, 8, func(request []byte) []byte {
+				adu, _ := (&rtuPackager{SlaveID: request[0]}).Encode(response)
+				return adu
+			}))
+			handler.SlaveID = 1
+			return handler
+		}},
+		{"ASCII", func(t *testing.T) ClientHandler {
+			handler := NewASCIIStreamClientHandler(streamResponder(t, 0, func(request []byte) []byte {
+				adu, _ := (&asciiPackager{SlaveID: 1}).Encode(response)
+				return adu
+			}))
+			handler.SlaveID = 1
+			return handler
+		}},
+		{"TCP", func(t *testing.T) ClientHandler {
+			handler := NewTCPStreamClientHandler(streamResponder(t, tcpHeaderSize+5, func(request []byte) []byte {
+				adu := append([]byte(nil), request[:tcpHeaderSize]...)
+				adu[5] = 5
+				return append(adu, response.FunctionCode, 2, 0xCA, 0xFE)
+			}))
+			handler.SlaveID = 1
+			return handler
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := tt.handler(t)
+			client := NewClient(handler)
+			for i := 0; i < 2; i++ {
+				results, err := client.ReadHoldingRegisters(context.Background(), 0, 1)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(results, []byte{0xCA, 0xFE}) {
+					t.Fatalf("unexpected results % x", results)
+				}
+			}
+		})
+	}
+}
+
+func TestStreamClientHandlerTimeout(t *testing.T) {
+	silent := func(t *testing.T, size int) StreamOpenFunc {
+		return streamResponder(t, size, func([]byte) []byte { return nil })
+	}
+
+	rtu := NewRTUStreamClientHandler(silent(t, 8))
+	rtu.Timeout = 50 * time.Millisecond
+	tcp := NewTCPStreamClientHandler(silent(t, tcpHeaderSize+5))
+	tcp.Timeout = 50 * time.Millisecond
+	defer rtu.Close()
+	defer tcp.Close()
+
+	for _, handler := range []ClientHandler{rtu, tcp} {
+		start := time.Now()
+		_, err := NewClient(handler).ReadHoldingRegisters(context.Background(), 0, 1)
+		if !errors.Is(err, ErrTimeout) {
+			t.Fatalf("expected timeout, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Fatalf("expected the blocked read to end at the deadline, took %v", elapsed)
+		}
+	}
+}
+
+func TestStreamConnDeadlinePassthrough(t *testing.T) {
+	client, device := net.Pipe()
+	defer device.Close()
+	conn := newStreamConn(client)
+	defer conn.Close()
+
+	if err := conn.SetReadDeadline(time.Now().Add(10 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Read(make([]byte, 1)); !isTimeout(err) {
+		t.Fatalf("expected the deadline of the stream to be used, got %v", err)
+	}
+	if conn.started {
+		t.Fatal("expected no background reader for streams with deadlines")
+	}
+}
@@ -0,0 +1,49 @@
+package modbus
+
+import (
+	"bytes"
+	"errors"
+)
+
+// TCPOverUDPClientHandler implements Packager and Transporter interface for
+// modbus TCP (MBAP) frames carried in UDP datagrams.
+type TCPOverUDPClientHandler struct {
+	tcpPackager
+	udpTransporter
+}
+
+// NewTCPOverUDPClientHandler allocates and initializes a TCPOverUDPClientHandler.
+func NewTCPOverUDPClientHandler(address string, options ...UDPClientHandlerOption) *TCPOverUDPClientHandler {
+	handler := &TCPOverUDPClientHandler{}
+	handler.configure(address, handler.responseMatches, tcpFrameAttrs, options)
+	return handler
+}
+
+// responseMatches is tcpResponseMatches counting the datagrams discarded for
+// their transaction id.
+func (mb *TCPOverUDPClientHandler) responseMatches(aduRequest, aduResponse []byte) bool {
+	if tcpResponseMatches(aduRequest, aduResponse) {
+		return true
+	}
+	var mismatch *TransactionIDMismatchError
+	if errors.As(verify(aduRequest, aduResponse), &mismatch) {
+		mb.observer().TransactionIDMismatch()
+	}
+	return false
+}
+
+// TCPOverUDPClient creates TCP over UDP client with default handler and given connect string.
+func TCPOverUDPClient(address string) Client {
+	handler := NewTCPOverUDPClientHandler(address)
+	return NewClient(handler)
+}
+
+// tcpResponseMatches reports whether a TCP datagram has the transaction id,
+// protocol id and unit id of the request. Responses to earlier transmissions
+// of the same request match as well.
+func tcpResponseMatches(aduRequest, aduResponse []byte) bool {
+	if len(aduRequest) <= tcpHeaderSize || len(aduResponse) <= tcpHeaderSize {
+		return false
+	}
+	return bytes.Equal(aduResponse[:4], aduRequest[:4]) && aduResponse[6] == aduRequest[6]
+}
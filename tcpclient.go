@@ -11,6 +11,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net"
 	"sync"
 	"sync/atomic"
@@ -37,10 +38,25 @@ func (length ErrTCPHeaderLength) Error() string {
 		length, tcpMaxLength-tcpHeaderSize+1)
 }
 
+// Is reports whether target is ErrFraming.
+func (length ErrTCPHeaderLength) Is(target error) bool {
+	return target == ErrFraming
+}
+
 // TCPClientHandler implements Packager and Transporter interface.
 type TCPClientHandler struct {
 	tcpPackager
 	tcpTransporter
+
+	// Heartbeat interval of a connection without requests. A heartbeat
+	// request not answered within Timeout, or the interval if Timeout is
+	// zero, closes the connection, which is then dialed again right away.
+	// Heartbeats do not reset IdleTimeout. If zero, no heartbeats are sent.
+	HeartbeatInterval time.Duration
+	// Heartbeat request, addressed to the unit id of the last request. If
+	// nil, holding register 0 is read. Exception responses count as answers
+	// too.
+	Heartbeat *ProtocolDataUnit
 }
 
 // NewTCPClientHandler allocates a new TCPClientHandler with the given options.
@@ -53,11 +69,41 @@ func NewTCPClientHandler(address string, options ...TCPClientHandlerOption) *TCP
 	h.Timeout = tcpTimeout
 	h.IdleTimeout = tcpIdleTimeout
 	if h.Dial == nil {
-		h.Dial = defaultDialFunc(h.Timeout)
+		h.Dial = h.dial
 	}
+	h.heartbeater = h
 	return h
 }
 
+func (h *TCPClientHandler) heartbeatInterval() time.Duration {
+	return h.HeartbeatInterval
+}
+
+func (h *TCPClientHandler) encodeHeartbeat(unitID byte) ([]byte, error) {
+	pdu := h.Heartbeat
+	if pdu == nil {
+		pdu = &ProtocolDataUnit{FunctionCode: FuncCodeReadHoldingRegisters, Data: dataBlock(0, 1)}
+	}
+	return h.tcpPackager.encode(unitID, pdu)
+}
+
+// Connect establishes a new connection to the address in Address. Heartbeats
+// are addressed to the slave id at the time of the call until the first
+// request.
+func (h *TCPClientHandler) Connect(ctx context.Context) error {
+	slaveID := h.SlaveID
+	h.mu.Lock()
+	h.unitID = slaveID
+	h.mu.Unlock()
+	return h.tcpTransporter.Connect(ctx)
+}
+
+// tcpHeartbeater configures the heartbeats of a tcpTransporter.
+type tcpHeartbeater interface {
+	heartbeatInterval() time.Duration
+	encodeHeartbeat(unitID byte) ([]byte, error)
+}
+
 // TCPClientHandlerOption configures a TCPClientHandler.
 type TCPClientHandlerOption func(*TCPClientHandler)
 
@@ -76,6 +122,13 @@ func defaultDialFunc(timeout time.Duration) DialFunc {
 	return (&net.Dialer{Timeout: timeout}).DialContext
 }
 
+// WithKeepAlive returns a TCPClientHandlerOption that sets KeepAlive.
+func WithKeepAlive(period time.Duration) TCPClientHandlerOption {
+	return func(h *TCPClientHandler) {
+		h.KeepAlive = period
+	}
+}
+
 // WithTLSConfig returns a TCPClientHandlerOption that enables TLS encryption with the given options.
 func WithTLSConfig(config *tls.Config) TCPClientHandlerOption {
 	return func(h *TCPClientHandler) {
@@ -102,6 +155,11 @@ func (mb *tcpPackager) SetSlave(slaveID byte) {
 	mb.SlaveID = slaveID
 }
 
+// slave returns the modbus slave id used for the next client operations
+func (mb *tcpPackager) slave() byte {
+	return mb.SlaveID
+}
+
 // Encode adds modbus application protocol header:
 //
 //	Transaction identifier: 2 bytes
@@ -111,6 +169,11 @@ func (mb *tcpPackager) SetSlave(slaveID byte) {
 //	Function code: 1 byte
 //	Data: n bytes
 func (mb *tcpPackager) Encode(pdu *ProtocolDataUnit) (adu []byte, err error) {
+	return mb.encode(mb.SlaveID, pdu)
+}
+
+// encode encodes pdu like Encode, addressed to unitID instead of SlaveID.
+func (mb *tcpPackager) encode(unitID byte, pdu *ProtocolDataUnit) (adu []byte, err error) {
 	adu = make([]byte, tcpHeaderSize+1+len(pdu.Data))
 
 	// Transaction identifier
@@ -122,7 +185,7 @@ func (mb *tcpPackager) Encode(pdu *ProtocolDataUnit) (adu []byte, err error) {
 	length := uint16(1 + 1 + len(pdu.Data))
 	binary.BigEndian.PutUint16(adu[4:], length)
 	// Unit identifier
-	adu[6] = mb.SlaveID
+	adu[6] = unitID
 
 	// PDU
 	adu[tcpHeaderSize] = pdu.FunctionCode
@@ -146,7 +209,7 @@ func (mb *tcpPackager) Decode(adu []byte) (pdu *ProtocolDataUnit, err error) {
 	length := binary.BigEndian.Uint16(adu[4:])
 	pduLength := len(adu) - tcpHeaderSize
 	if pduLength <= 0 || pduLength != int(length-1) {
-		err = fmt.Errorf("modbus: length in response '%v' does not match pdu data length '%v'", length-1, pduLength)
+		err = errorf(ErrFraming, "modbus: length in response '%v' does not match pdu data length '%v'", length-1, pduLength)
 		return
 	}
 	pdu = &ProtocolDataUnit{}
@@ -175,21 +238,37 @@ type tcpTransporter struct {
 	ConnectDelay time.Duration
 	// Transmission logger
 	Logger Logger
+	// Structured transmission logger, takes precedence over Logger
+	StructuredLogger *slog.Logger
+	// Transmission observer, e.g. Stats
+	Observer Observer
 
 	// Dial specifies the dial function for creating TCP connections.
 	// If nil, the transporter dials using the net package.
 	Dial DialFunc
+	// KeepAlive is the period of the TCP keep-alive probes of the default
+	// Dial function, detecting dead peers while the connection is idle. If
+	// zero, the system default is used; if negative, keep-alive probes are
+	// disabled. It has no effect with a custom Dial function.
+	KeepAlive time.Duration
 
 	// TCP connection
-	mu           sync.Mutex
-	conn         net.Conn
-	closeTimer   *time.Timer
-	lastActivity time.Time
+	mu             sync.Mutex
+	conn           net.Conn
+	closeTimer     *time.Timer
+	heartbeatTimer *time.Timer
+	lastActivity   time.Time
+
+	// heartbeater, if set, configures the heartbeats
+	heartbeater tcpHeartbeater
+	// unitID of the last request, addressed by the heartbeats
+	unitID byte
 
 	lastAttemptedTransactionID  uint16
 	lastSuccessfulTransactionID uint16
 
-	tlsConfig *tls.Config
+	tlsConfig    *tls.Config
+	printfLogger printfLogger
 }
 
 // helper value to signify what to do in Send
@@ -205,11 +284,15 @@ const (
 func (mb *tcpTransporter) Send(ctx context.Context, aduRequest []byte) (aduResponse []byte, err error) {
 	mb.mu.Lock()
 	defer mb.mu.Unlock()
+	defer func() { err = transportError(err) }()
 
 	if mb.IdleTimeout == 0 {
 		defer mb.close()
 	}
 
+	if len(aduRequest) > tcpHeaderSize {
+		mb.unitID = aduRequest[6]
+	}
 	var data [tcpMaxLength]byte
 	linkRecoveryDeadline := time.Now().Add(mb.LinkRecoveryTimeout)
 	protocolRecoveryDeadline := time.Now().Add(mb.ProtocolRecoveryTimeout)
@@ -234,7 +317,9 @@ func (mb *tcpTransporter) Send(ctx context.Context, aduRequest []byte) (aduRespo
 		}
 
 		// Send data
-		mb.logf("modbus: send % x", aduRequest)
+		if logger := mb.logger(); logger.Enabled(ctx, slog.LevelDebug) {
+			logger.Debug("modbus: send", tcpFrameAttrs(LogDirectionSend, aduRequest)...)
+		}
 		if _, err = mb.conn.Write(aduRequest); err != nil {
 			// Close on any write error, regardless of its type. [net.Conn.Write]
 			// has no context parameter, so every error it returns is an OS- or
@@ -253,18 +338,18 @@ func (mb *tcpTransporter) Send(ctx context.Context, aduRequest []byte) (aduRespo
 			// the only safe action is to close and reconnect, so that the next
 			// Send() dials fresh via connect() (which is a no-op when
 			// mb.conn != nil).
-			mb.logf("modbus: write error, closing connection: %v", err)
+			mb.logger().Warn("modbus: write error, closing connection", errorAttrs(err, LogActionClose)...)
 			mb.close()
 			err = fmt.Errorf("modbus: write: %w", err)
 			return
 		}
 
+		mb.observer().RequestSent(len(aduRequest))
+		sent := time.Now()
+
 		mb.lastAttemptedTransactionID = binary.BigEndian.Uint16(aduRequest)
 		var res readResult
 		aduResponse, res, err = mb.readResponse(aduRequest, data[:], linkRecoveryDeadline, protocolRecoveryDeadline)
-		if err != nil {
-			mb.logf("modbus: read response error: %v, res: %v", err, res)
-		}
 		switch res {
 		case readResultDone:
 			if err != nil {
@@ -273,22 +358,30 @@ func (mb *tcpTransporter) Send(ctx context.Context, aduRequest []byte) (aduRespo
 					// Timeout: the device may be slow. Keep the connection open so
 					// the late response can be drained on the next Send via
 					// ProtocolRecoveryTimeout transaction-ID matching.
-					mb.logf("modbus: read response timeout, keeping connection: %v", err)
+					mb.logger().Warn("modbus: read response timeout, keeping connection",
+						append(errorAttrs(err, LogActionKeep), durationAttr(sent))...)
+					mb.observer().Timeout()
 				} else {
-					mb.logf("modbus: read response error: closing connection: %v", err)
+					mb.logger().Warn("modbus: read response error, closing connection",
+						append(errorAttrs(err, LogActionClose), durationAttr(sent))...)
 					mb.close()
 				}
 				err = fmt.Errorf("modbus: read response: %w", err)
 			} else {
 				mb.lastSuccessfulTransactionID = binary.BigEndian.Uint16(aduResponse)
+				mb.observer().ResponseReceived(len(aduResponse), time.Since(sent))
+				if logger := mb.logger(); logger.Enabled(ctx, slog.LevelDebug) {
+					logger.Debug("modbus: recv", append(tcpFrameAttrs(LogDirectionRecv, aduResponse), durationAttr(sent))...)
+				}
 			}
 			return
 		case readResultRetry:
-			mb.logf("modbus: retry reading response, because of %v", err)
+			mb.logger().Info("modbus: retry reading response", errorAttrs(err, LogActionRetry)...)
 			continue
 		case readResultCloseRetry:
-			mb.logf("modbus: close connection and retry reading response, because of %v", err)
+			mb.logger().Warn("modbus: close connection and retry reading response", errorAttrs(err, LogActionReconnect)...)
 			mb.close()
+			mb.observer().Reconnect()
 			select {
 			case <-ctx.Done():
 				return nil, ctx.Err()
@@ -296,7 +389,7 @@ func (mb *tcpTransporter) Send(ctx context.Context, aduRequest []byte) (aduRespo
 				continue
 			}
 		default:
-			mb.logf("modbus: unhandled read result %v", res)
+			mb.logger().Error("modbus: unhandled read result", slog.Int("result", int(res)))
 			return nil, fmt.Errorf("modbus: unhandled read result %v", res)
 		}
 	}
@@ -306,7 +399,6 @@ func (mb *tcpTransporter) shouldRecover(err error) bool {
 	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET)
 }
 
-
 func (mb *tcpTransporter) readResponse(aduRequest []byte, data []byte, recoveryDeadline time.Time, protocolDeadline time.Time) (aduResponse []byte, res readResult, err error) {
 	// res is readResultDone by default, which either means we succeeded or err contains the fatal error
 	for {
@@ -316,7 +408,7 @@ func (mb *tcpTransporter) readResponse(aduRequest []byte, data []byte, recoveryD
 				return
 			}
 			if mb.shouldRecover(err) {
-				mb.logf("modbus: connection closed by remote side: %v", err)
+				mb.logger().Warn("modbus: connection closed by remote side", errorAttrs(err, LogActionReconnect)...)
 				res = readResultCloseRetry
 			}
 			return
@@ -328,7 +420,7 @@ func (mb *tcpTransporter) readResponse(aduRequest []byte, data []byte, recoveryD
 				return
 			}
 			if mb.shouldRecover(err) {
-				mb.logf("modbus: connection closed by remote side: %v", err)
+				mb.logger().Warn("modbus: connection closed by remote side", errorAttrs(err, LogActionReconnect)...)
 				res = readResultCloseRetry
 				return
 			}
@@ -343,14 +435,18 @@ func (mb *tcpTransporter) readResponse(aduRequest []byte, data []byte, recoveryD
 
 		err = verify(aduRequest, aduResponse)
 		if err != nil {
-			mb.logf("modbus: verify error: %v", err)
+			mb.logger().Info("modbus: verify error", append(tcpFrameAttrs(LogDirectionRecv, aduResponse), errorAttrs(err, "")...)...)
+			v, mismatch := err.(*TransactionIDMismatchError)
+			if mismatch {
+				mb.observer().TransactionIDMismatch()
+			}
 			// recovery disabled or deadline reached - report error
 			if mb.ProtocolRecoveryTimeout == 0 || time.Until(protocolDeadline) < 0 {
 				return
 			}
-			if v, ok := err.(errTransactionIDMismatch); ok {
-				if (v.got > mb.lastSuccessfulTransactionID && v.got < mb.lastAttemptedTransactionID) ||
-					(mb.lastAttemptedTransactionID < mb.lastSuccessfulTransactionID && (v.got > mb.lastSuccessfulTransactionID || v.got < mb.lastAttemptedTransactionID)) {
+			if mismatch {
+				if (v.Got > mb.lastSuccessfulTransactionID && v.Got < mb.lastAttemptedTransactionID) ||
+					(mb.lastAttemptedTransactionID < mb.lastSuccessfulTransactionID && (v.Got > mb.lastSuccessfulTransactionID || v.Got < mb.lastAttemptedTransactionID)) {
 					// most likely, we simply had a timeout for the earlier query and now read the (late) response. Ignore it
 					// and assume that the response will come *without* sending another query. (If we send another query
 					// with transactionId X+1 here, we would again get a transactionMismatchError if the response to
@@ -363,7 +459,6 @@ func (mb *tcpTransporter) readResponse(aduRequest []byte, data []byte, recoveryD
 			// other error - report
 			return
 		}
-		mb.logf("modbus: recv % x\n", aduResponse)
 		return // everything is OK
 
 	}
@@ -395,28 +490,52 @@ func (mb *tcpTransporter) processResponse(data []byte) (aduResponse []byte, err
 	return
 }
 
-type errTransactionIDMismatch struct {
-	got, expected uint16
+// TransactionIDMismatchError is returned if the transaction id of a TCP
+// response does not match the request. It matches ErrResponseMismatch.
+type TransactionIDMismatchError struct {
+	Got, Expected uint16
+}
+
+// Error implements the error interface
+func (e *TransactionIDMismatchError) Error() string {
+	return fmt.Sprintf("modbus: response transaction id '%v' does not match request '%v'", e.Got, e.Expected)
 }
 
-func (e errTransactionIDMismatch) Error() string {
-	return fmt.Sprintf("modbus: response transaction id '%v' does not match request '%v'", e.got, e.expected)
+// Is reports whether target is ErrResponseMismatch.
+func (e *TransactionIDMismatchError) Is(target error) bool {
+	return target == ErrResponseMismatch
 }
 
-type errProtocolIDMismatch struct {
-	got, expected uint16
+// ProtocolIDMismatchError is returned if the protocol id of a TCP response
+// does not match the request. It matches ErrResponseMismatch.
+type ProtocolIDMismatchError struct {
+	Got, Expected uint16
 }
 
-func (e errProtocolIDMismatch) Error() string {
-	return fmt.Sprintf("modbus: response protocol id '%v' does not match request '%v'", e.got, e.expected)
+// Error implements the error interface
+func (e *ProtocolIDMismatchError) Error() string {
+	return fmt.Sprintf("modbus: response protocol id '%v' does not match request '%v'", e.Got, e.Expected)
 }
 
-type errUnitIDMismatch struct {
-	got, expected byte
+// Is reports whether target is ErrResponseMismatch.
+func (e *ProtocolIDMismatchError) Is(target error) bool {
+	return target == ErrResponseMismatch
 }
 
-func (e errUnitIDMismatch) Error() string {
-	return fmt.Sprintf("modbus: response unit id '%v' does not match request '%v'", e.got, e.expected)
+// UnitIDMismatchError is returned if the unit id of a TCP response does not
+// match the request. It matches ErrResponseMismatch.
+type UnitIDMismatchError struct {
+	Got, Expected byte
+}
+
+// Error implements the error interface
+func (e *UnitIDMismatchError) Error() string {
+	return fmt.Sprintf("modbus: response unit id '%v' does not match request '%v'", e.Got, e.Expected)
+}
+
+// Is reports whether target is ErrResponseMismatch.
+func (e *UnitIDMismatchError) Is(target error) bool {
+	return target == ErrResponseMismatch
 }
 
 const sizeInt16 = 2
@@ -433,19 +552,19 @@ func verify(aduRequest []byte, aduResponse []byte) (err error) {
 	responseVal := binary.BigEndian.Uint16(aduResponse)
 	requestVal := binary.BigEndian.Uint16(aduRequest)
 	if responseVal != requestVal {
-		err = errTransactionIDMismatch{got: responseVal, expected: requestVal}
+		err = &TransactionIDMismatchError{Got: responseVal, Expected: requestVal}
 		return
 	}
 	// Protocol id
 	responseVal = binary.BigEndian.Uint16(aduResponse[2:])
 	requestVal = binary.BigEndian.Uint16(aduRequest[2:])
 	if responseVal != requestVal {
-		err = errProtocolIDMismatch{got: responseVal, expected: requestVal}
+		err = &ProtocolIDMismatchError{Got: responseVal, Expected: requestVal}
 		return
 	}
 	// Unit id (1 byte)
 	if aduResponse[6] != aduRequest[6] {
-		err = errUnitIDMismatch{got: aduResponse[6], expected: aduRequest[6]}
+		err = &UnitIDMismatchError{Got: aduResponse[6], Expected: aduRequest[6]}
 		return
 	}
 	return
@@ -460,6 +579,11 @@ func (mb *tcpTransporter) Connect(ctx context.Context) error {
 	return mb.connect(ctx)
 }
 
+// dial is the default Dial function, connecting within Timeout.
+func (mb *tcpTransporter) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	return (&net.Dialer{Timeout: mb.Timeout, KeepAlive: mb.KeepAlive}).DialContext(ctx, network, address)
+}
+
 func (mb *tcpTransporter) connect(ctx context.Context) error {
 	if mb.conn == nil {
 		conn, err := mb.Dial(ctx, "tcp", mb.Address)
@@ -470,6 +594,7 @@ func (mb *tcpTransporter) connect(ctx context.Context) error {
 			conn = tls.Client(conn, mb.tlsConfig)
 		}
 		mb.conn = conn
+		mb.startHeartbeatTimer()
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
@@ -514,10 +639,27 @@ func (mb *tcpTransporter) flush(b []byte) (err error) {
 	return
 }
 
-func (mb *tcpTransporter) logf(format string, v ...interface{}) {
-	if mb.Logger != nil {
-		mb.Logger.Printf(format, v...)
+func (mb *tcpTransporter) logger() *slog.Logger {
+	return mb.printfLogger.of(mb.StructuredLogger, mb.Logger)
+}
+
+func (mb *tcpTransporter) observer() Observer {
+	if mb.Observer != nil {
+		return mb.Observer
 	}
+	return nopObserver{}
+}
+
+func (mb *tcpTransporter) transportAddress() string {
+	return mb.Address
+}
+
+func (mb *tcpTransporter) setTimeout(timeout time.Duration) time.Duration {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	previous := mb.Timeout
+	mb.Timeout = timeout
+	return previous
 }
 
 // closeLocked closes current connection. Caller must hold the mutex before calling this method.
@@ -526,6 +668,10 @@ func (mb *tcpTransporter) close() (err error) {
 		err = mb.conn.Close()
 		mb.conn = nil
 	}
+	if mb.heartbeatTimer != nil {
+		mb.heartbeatTimer.Stop()
+		mb.heartbeatTimer = nil
+	}
 	return
 }
 
@@ -539,7 +685,94 @@ func (mb *tcpTransporter) closeIdle() {
 	}
 
 	if idle := time.Since(mb.lastActivity); idle >= mb.IdleTimeout {
-		mb.logf("modbus: closing connection due to idle timeout: %v", idle)
+		mb.logger().Debug("modbus: closing connection due to idle timeout", slog.Duration("idle", idle))
+		mb.close()
+	}
+}
+
+// startHeartbeatTimer starts sending heartbeats on a new connection. Caller
+// must hold the mutex before calling this method.
+func (mb *tcpTransporter) startHeartbeatTimer() {
+	if mb.heartbeater == nil || mb.heartbeater.heartbeatInterval() <= 0 {
+		return
+	}
+	mb.heartbeatTimer = time.AfterFunc(mb.heartbeater.heartbeatInterval(), mb.heartbeat)
+}
+
+// heartbeat sends the heartbeat request if the connection had no requests for
+// the heartbeat interval, and dials again if it is not answered.
+func (mb *tcpTransporter) heartbeat() {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	if mb.conn == nil || mb.heartbeatTimer == nil {
+		return
+	}
+	interval := mb.heartbeater.heartbeatInterval()
+	if idle := time.Since(mb.lastActivity); idle < interval {
+		mb.heartbeatTimer.Reset(interval - idle)
+		return
+	}
+
+	// a half-open connection must not block the requests waiting for the mutex
+	timeout := mb.Timeout
+	if timeout <= 0 {
+		timeout = interval
+	}
+	err := mb.sendHeartbeat(timeout)
+	if err == nil {
+		mb.heartbeatTimer.Reset(interval)
+		return
+	}
+	mb.logger().Warn("modbus: heartbeat failed, reconnecting", errorAttrs(err, LogActionReconnect)...)
+	mb.close()
+	mb.observer().Reconnect()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := mb.connect(ctx); err != nil {
+		// dialed again by the next request
+		mb.logger().Warn("modbus: heartbeat reconnect failed", errorAttrs(err, "")...)
 		mb.close()
 	}
 }
+
+// sendHeartbeat sends the heartbeat request and reads the response within
+// timeout. Caller must hold the mutex before calling this method.
+func (mb *tcpTransporter) sendHeartbeat(timeout time.Duration) error {
+	aduRequest, err := mb.heartbeater.encodeHeartbeat(mb.unitID)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(timeout)
+	if err = mb.conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+	if mb.Timeout <= 0 {
+		// requests do not set a deadline of their own
+		defer mb.conn.SetDeadline(time.Time{})
+	}
+	if logger := mb.logger(); logger.Enabled(context.Background(), slog.LevelDebug) {
+		logger.Debug("modbus: send heartbeat", tcpFrameAttrs(LogDirectionSend, aduRequest)...)
+	}
+	if _, err = mb.conn.Write(aduRequest); err != nil {
+		return err
+	}
+	mb.lastAttemptedTransactionID = binary.BigEndian.Uint16(aduRequest)
+
+	var data [tcpMaxLength]byte
+	for {
+		// no link recovery
+		aduResponse, _, err := mb.readResponse(aduRequest, data[:], time.Now(), deadline)
+		var mismatch *TransactionIDMismatchError
+		if errors.As(err, &mismatch) {
+			// late response to an earlier request, read on until the deadline
+			continue
+		}
+		if err != nil {
+			return transportError(err)
+		}
+		mb.lastSuccessfulTransactionID = binary.BigEndian.Uint16(aduResponse)
+		return nil
+	}
+}
@@ -623,6 +623,197 @@ func TestConnCaching(t *testing.T) {
 	})
 }
 
+// heartbeatServer accepts connections and sends the requests it reads to
+// requests. Requests on the first connection are answered only if answer is
+// set, with an exception response. If stale is set, every answer is preceded
+// by a late response to the previous transaction.
+func heartbeatServer(t *testing.T, answer, stale bool) (addr string, accepted <-chan struct{}, requests <-chan []byte) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	acceptedCh := make(chan struct{}, 10)
+	requestsCh := make(chan []byte, 100)
+	go func() {
+		for first := true; ; first = false {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+			acceptedCh <- struct{}{}
+			go func(answer bool) {
+				for {
+					request := make([]byte, tcpHeaderSize+5)
+					if _, err := io.ReadFull(conn, request); err != nil {
+						return
+					}
+					requestsCh <- request
+					if !answer {
+						continue
+					}
+					response := append(request[:tcpHeaderSize:tcpHeaderSize], request[tcpHeaderSize]|0x80, ExceptionCodeIllegalDataAddress)
+					binary.BigEndian.PutUint16(response[4:], 3)
+					if stale {
+						late := append([]byte(nil), response...)
+						binary.BigEndian.PutUint16(late, binary.BigEndian.Uint16(response)-1)
+						conn.Write(late)
+					}
+					conn.Write(response)
+				}
+			}(answer || !first)
+		}
+	}()
+	return ln.Addr().String(), acceptedCh, requestsCh
+}
+
+func TestTCPHeartbeat(t *testing.T) {
+	addr, accepted, requests := heartbeatServer(t, true, false)
+	handler := NewTCPClientHandler(addr, WithKeepAlive(time.Minute))
+	handler.SlaveID = 7
+	handler.IdleTimeout = -1
+	handler.HeartbeatInterval = 20 * time.Millisecond
+	if err := handler.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer handler.Close()
+
+	for i := 0; i < 3; i++ {
+		select {
+		case request := <-requests:
+			if request[6] != 7 || request[tcpHeaderSize] != FuncCodeReadHoldingRegisters {
+				t.Errorf("expected heartbeat reading holding register of unit 7, got % x", request)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected heartbeat %d", i+1)
+		}
+	}
+	<-accepted
+	select {
+	case <-accepted:
+		t.Error("expected answered heartbeats to keep the connection")
+	default:
+	}
+}
+
+func TestTCPHeartbeatSlaveID(t *testing.T) {
+	addr, _, requests := heartbeatServer(t, true, false)
+	handler := NewTCPClientHandler(addr)
+	handler.SlaveID = 7
+	handler.IdleTimeout = -1
+	handler.HeartbeatInterval = time.Millisecond
+	if err := handler.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer handler.Close()
+
+	// changing the slave id for the next request does not affect heartbeats
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				handler.SetSlave(9)
+			}
+		}
+	}()
+	for i := 0; i < 5; i++ {
+		select {
+		case request := <-requests:
+			if request[6] != 7 {
+				t.Fatalf("expected heartbeat to unit 7, got % x", request)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected heartbeat %d", i+1)
+		}
+	}
+}
+
+func TestTCPHeartbeatReconnects(t *testing.T) {
+	addr, accepted, requests := heartbeatServer(t, false, false)
+	handler := NewTCPClientHandler(addr)
+	handler.Timeout = 50 * time.Millisecond
+	handler.IdleTimeout = -1
+	handler.HeartbeatInterval = 20 * time.Millisecond
+	handler.Heartbeat = &ProtocolDataUnit{FunctionCode: FuncCodeReadInputRegisters, Data: []byte{0, 1, 0, 1}}
+	if err := handler.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer handler.Close()
+
+	<-accepted
+	select {
+	case request := <-requests:
+		if request[tcpHeaderSize] != FuncCodeReadInputRegisters {
+			t.Errorf("expected heartbeat reading input register, got % x", request)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected heartbeat")
+	}
+	select {
+	case <-accepted:
+	case <-time.After(time.Second):
+		t.Fatal("expected reconnect after unanswered heartbeat")
+	}
+}
+
+func TestTCPHeartbeatWithoutTimeout(t *testing.T) {
+	addr, accepted, _ := heartbeatServer(t, false, false)
+	handler := NewTCPClientHandler(addr)
+	handler.Timeout = 0
+	handler.IdleTimeout = -1
+	handler.HeartbeatInterval = 20 * time.Millisecond
+	if err := handler.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer handler.Close()
+
+	<-accepted
+	select {
+	case <-accepted:
+	case <-time.After(time.Second):
+		t.Fatal("expected reconnect after unanswered heartbeat")
+	}
+	// the heartbeat deadline does not apply to requests
+	time.Sleep(50 * time.Millisecond)
+	if _, err := NewClient(handler).ReadHoldingRegisters(context.Background(), 0, 1); !errors.Is(err, ErrIllegalDataAddress) {
+		t.Fatalf("expected exception response, got %v", err)
+	}
+}
+
+func TestTCPHeartbeatSkipsLateResponses(t *testing.T) {
+	addr, accepted, requests := heartbeatServer(t, true, true)
+	handler := NewTCPClientHandler(addr)
+	handler.Timeout = 50 * time.Millisecond
+	handler.IdleTimeout = -1
+	handler.HeartbeatInterval = 20 * time.Millisecond
+	if err := handler.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer handler.Close()
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-requests:
+		case <-time.After(time.Second):
+			t.Fatalf("expected heartbeat %d", i+1)
+		}
+	}
+	// let the last heartbeat be answered or time out
+	time.Sleep(handler.Timeout)
+	<-accepted
+	select {
+	case <-accepted:
+		t.Error("expected late responses to keep the connection")
+	default:
+	}
+}
+
 func BenchmarkTCPEncoder(b *testing.B) {
 	encoder := tcpPackager{
 		SlaveID: 10,
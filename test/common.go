@@ -8,6 +8,8 @@ import (
 	"runtime"
 	"strings"
 	"testing"
+
+	"github.com/grid-x/modbus/modbustest"
 )
 
 func AssertEquals(t *testing.T, expected, actual interface{}) {
@@ -29,3 +31,11 @@ func AssertEquals(t *testing.T, expected, actual interface{}) {
 		t.FailNow()
 	}
 }
+
+// newDevice returns a fake device answering all requests of ClientTestAll.
+func newDevice() *modbustest.Device {
+	device := modbustest.NewDevice()
+	// ClientTestReadFIFOQueue expects an empty queue
+	device.SetFIFOQueue(0x04DE)
+	return device
+}
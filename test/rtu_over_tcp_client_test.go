@@ -11,21 +11,23 @@ import (
 	"time"
 
 	"github.com/grid-x/modbus"
-)
-
-const (
-	rtuOverTCPDevice = "localhost:5021"
+	"github.com/grid-x/modbus/modbustest"
 )
 
 func TestRTUOverTCPClient(t *testing.T) {
-	// Diagslave does not support broadcast id.
-	handler := modbus.NewRTUOverTCPClientHandler(rtuOverTCPDevice)
+	server := modbustest.NewRTUOverTCPServer(map[byte]*modbustest.Device{17: newDevice()})
+	defer server.Close()
+
+	handler := modbus.NewRTUOverTCPClientHandler(server.Addr)
 	handler.SlaveID = 17
 	ClientTestAll(t, modbus.NewClient(handler))
 }
 
 func TestRTUOverTCPClientAdvancedUsage(t *testing.T) {
-	handler := modbus.NewRTUOverTCPClientHandler(rtuOverTCPDevice)
+	server := modbustest.NewRTUOverTCPServer(map[byte]*modbustest.Device{1: newDevice()})
+	defer server.Close()
+
+	handler := modbus.NewRTUOverTCPClientHandler(server.Addr)
 	handler.Timeout = 5 * time.Second
 	handler.SlaveID = 1
 	handler.Logger = log.Default()
@@ -11,19 +11,22 @@ import (
 	"time"
 
 	"github.com/grid-x/modbus"
-)
-
-const (
-	tcpDevice = "localhost:5020"
+	"github.com/grid-x/modbus/modbustest"
 )
 
 func TestTCPClient(t *testing.T) {
-	client := modbus.TCPClient(tcpDevice)
+	server := modbustest.NewTCPServer(map[byte]*modbustest.Device{0: newDevice()})
+	defer server.Close()
+
+	client := modbus.TCPClient(server.Addr)
 	ClientTestAll(t, client)
 }
 
 func TestTCPClientAdvancedUsage(t *testing.T) {
-	handler := modbus.NewTCPClientHandler(tcpDevice)
+	server := modbustest.NewTCPServer(map[byte]*modbustest.Device{1: newDevice()})
+	defer server.Close()
+
+	handler := modbus.NewTCPClientHandler(server.Addr)
 	handler.Timeout = 5 * time.Second
 	handler.SlaveID = 1
 	handler.Logger = log.Default()
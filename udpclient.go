@@ -0,0 +1,277 @@
+package modbus
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// Default timeout waiting for the response datagram of a transmission
+	udpTimeout = time.Second
+	// Default number of retransmissions after a timeout
+	udpRetries = 2
+	// Size of the receive buffer, large enough for any ADU
+	udpMaxSize = 1024
+)
+
+// UDPClientHandlerOption configures the transporter of a UDP client handler.
+type UDPClientHandlerOption func(*udpTransporter)
+
+// WithUDPDialer returns a UDPClientHandlerOption that sets a custom Dial
+// function. The returned connection must be connected to a single remote
+// address, as returned by dialing "udp".
+func WithUDPDialer(d DialFunc) UDPClientHandlerOption {
+	return func(mb *udpTransporter) {
+		mb.Dial = d
+	}
+}
+
+// udpTransporter implements Transporter interface for datagram transports,
+// where every datagram carries exactly one ADU.
+type udpTransporter struct {
+	// Connect string
+	Address string
+	// Timeout waiting for the response to a single transmission
+	Timeout time.Duration
+	// Number of retransmissions of the request after a timeout
+	Retries int
+	// Idle timeout to close the connection.
+	// If zero or negative, connections do not time out.
+	IdleTimeout time.Duration
+	// Transmission logger
+	Logger Logger
+	// Structured transmission logger, takes precedence over Logger
+	StructuredLogger *slog.Logger
+	// Transmission observer, e.g. Stats
+	Observer Observer
+
+	// Dial specifies the dial function for creating UDP connections.
+	// If nil, the transporter dials using the net package.
+	Dial DialFunc
+
+	// matches reports whether a datagram is the response to the request,
+	// other datagrams are discarded. If nil, every datagram is accepted.
+	matches func(aduRequest, aduResponse []byte) bool
+	// frameAttrs returns the log attributes of a frame
+	frameAttrs func(direction string, adu []byte) []any
+
+	// UDP connection
+	mu           sync.Mutex
+	conn         net.Conn
+	closeTimer   *time.Timer
+	lastActivity time.Time
+
+	printfLogger printfLogger
+}
+
+// configure sets the default timeouts and the framing, then applies options.
+func (mb *udpTransporter) configure(address string, matches func(aduRequest, aduResponse []byte) bool,
+	frameAttrs func(direction string, adu []byte) []any, options []UDPClientHandlerOption) {
+	mb.Address = address
+	mb.Timeout = udpTimeout
+	mb.Retries = udpRetries
+	mb.IdleTimeout = tcpIdleTimeout
+	mb.matches = matches
+	mb.frameAttrs = frameAttrs
+	for _, o := range options {
+		o(mb)
+	}
+}
+
+// Send sends the request in a datagram and waits for the datagram with the
+// matching response. The request is retransmitted after Timeout up to Retries
+// times.
+func (mb *udpTransporter) Send(ctx context.Context, aduRequest []byte) (aduResponse []byte, err error) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	defer func() { err = transportError(err) }()
+
+	// Check ADU request length
+	if len(aduRequest) < 2 {
+		err = ErrADURequestLength(len(aduRequest))
+		return
+	}
+
+	// Establish a new connection if not connected
+	if err = mb.connect(ctx); err != nil {
+		err = fmt.Errorf("modbus: connect: %w", err)
+		return
+	}
+	// Set timer to close when idle
+	mb.lastActivity = time.Now()
+	mb.startCloseTimer()
+
+	var data [udpMaxSize]byte
+	for attempt := 0; ; attempt++ {
+		deadline := time.Time{}
+		if mb.Timeout > 0 {
+			deadline = time.Now().Add(mb.Timeout)
+		}
+		if d, ok := ctx.Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+			deadline = d
+		}
+		if err = mb.conn.SetDeadline(deadline); err != nil {
+			err = fmt.Errorf("modbus: set deadline: %w", err)
+			return
+		}
+
+		if logger := mb.logger(); logger.Enabled(ctx, slog.LevelDebug) {
+			logger.Debug("modbus: send", mb.attrs(LogDirectionSend, aduRequest)...)
+		}
+		if _, err = mb.conn.Write(aduRequest); err != nil {
+			mb.logger().Warn("modbus: write error, closing connection", errorAttrs(err, LogActionClose)...)
+			mb.close()
+			err = fmt.Errorf("modbus: write: %w", err)
+			return
+		}
+		mb.observer().RequestSent(len(aduRequest))
+		sent := time.Now()
+
+		aduResponse, err = mb.readResponse(aduRequest, data[:])
+		if err == nil {
+			mb.observer().ResponseReceived(len(aduResponse), time.Since(sent))
+			if logger := mb.logger(); logger.Enabled(ctx, slog.LevelDebug) {
+				logger.Debug("modbus: recv", append(mb.attrs(LogDirectionRecv, aduResponse), durationAttr(sent))...)
+			}
+			return
+		}
+		if !isTimeout(err) {
+			mb.logger().Warn("modbus: read error", append(errorAttrs(err, ""), durationAttr(sent))...)
+			err = fmt.Errorf("modbus: read response: %w", err)
+			return
+		}
+		mb.observer().Timeout()
+		if attempt >= mb.Retries || ctx.Err() != nil {
+			mb.logger().Warn("modbus: read response timeout", append(errorAttrs(err, ""), durationAttr(sent))...)
+			err = fmt.Errorf("modbus: read response: %w", err)
+			return
+		}
+		mb.logger().Info("modbus: read response timeout, retransmitting", append(errorAttrs(err, LogActionRetry), durationAttr(sent))...)
+	}
+}
+
+// readResponse reads datagrams until one matches the request. Datagrams of
+// other requests or slaves are discarded.
+func (mb *udpTransporter) readResponse(aduRequest, data []byte) ([]byte, error) {
+	for {
+		n, err := mb.conn.Read(data)
+		if err != nil {
+			return nil, err
+		}
+		datagram := data[:n]
+		if mb.matches == nil || mb.matches(aduRequest, datagram) {
+			return append([]byte(nil), datagram...), nil
+		}
+		if logger := mb.logger(); logger.Enabled(context.Background(), slog.LevelDebug) {
+			logger.Debug("modbus: discarding stray datagram", mb.attrs(LogDirectionRecv, datagram)...)
+		}
+	}
+}
+
+func (mb *udpTransporter) attrs(direction string, adu []byte) []any {
+	if mb.frameAttrs == nil {
+		return []any{slog.String(LogKeyDirection, direction), slog.Any(LogKeyPayload, hexPayload(adu))}
+	}
+	return mb.frameAttrs(direction, adu)
+}
+
+func (mb *udpTransporter) logger() *slog.Logger {
+	return mb.printfLogger.of(mb.StructuredLogger, mb.Logger)
+}
+
+func (mb *udpTransporter) observer() Observer {
+	if mb.Observer != nil {
+		return mb.Observer
+	}
+	return nopObserver{}
+}
+
+func (mb *udpTransporter) transportAddress() string {
+	return mb.Address
+}
+
+func (mb *udpTransporter) setTimeout(timeout time.Duration) time.Duration {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	previous := mb.Timeout
+	mb.Timeout = timeout
+	return previous
+}
+
+// Connect establishes a new connection to the address in Address.
+func (mb *udpTransporter) Connect(ctx context.Context) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	return mb.connect(ctx)
+}
+
+// connect establishes a new connection to the address in Address. Caller must hold the mutex before calling this method.
+// Since UDP is connectionless this does little more than setting up the connection object.
+func (mb *udpTransporter) connect(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+	if mb.conn == nil {
+		dial := mb.Dial
+		if dial == nil {
+			dial = (&net.Dialer{}).DialContext
+		}
+		conn, err := dial(ctx, "udp", mb.Address)
+		if err != nil {
+			return err
+		}
+		mb.conn = conn
+	}
+	return nil
+}
+
+func (mb *udpTransporter) startCloseTimer() {
+	if mb.IdleTimeout <= 0 {
+		return
+	}
+	if mb.closeTimer == nil {
+		mb.closeTimer = time.AfterFunc(mb.IdleTimeout, mb.closeIdle)
+	} else {
+		mb.closeTimer.Reset(mb.IdleTimeout)
+	}
+}
+
+// Close closes current connection.
+func (mb *udpTransporter) Close() error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	return mb.close()
+}
+
+// close closes current connection. Caller must hold the mutex before calling this method.
+// Since UDP is connectionless this does little more than freeing up the connection object.
+func (mb *udpTransporter) close() (err error) {
+	if mb.conn != nil {
+		err = mb.conn.Close()
+		mb.conn = nil
+	}
+	return
+}
+
+// closeIdle closes the connection if last activity is passed behind IdleTimeout.
+func (mb *udpTransporter) closeIdle() {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	if mb.IdleTimeout <= 0 {
+		return
+	}
+
+	if idle := time.Since(mb.lastActivity); idle >= mb.IdleTimeout {
+		mb.logger().Debug("modbus: closing connection due to idle timeout", slog.Duration("idle", idle))
+		mb.close()
+	}
+}
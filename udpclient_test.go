@@ -0,0 +1,174 @@
+package modbus
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// udpResponder answers the datagrams of a UDP socket with the datagrams
+// returned by respond, in order. The request is passed with the number of
+// requests received so far.
+func udpResponder(t *testing.T, respond func(n int, request []byte) [][]byte) (addr string, requests func() int) {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	count := make(chan int, 1)
+	count <- 0
+	go func() {
+		buf := make([]byte, udpMaxSize)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			i := <-count + 1
+			count <- i
+			for _, datagram := range respond(i, buf[:n]) {
+				conn.WriteTo(datagram, addr)
+			}
+		}
+	}()
+	return conn.LocalAddr().String(), func() int {
+		i := <-count
+		count <- i
+		return i
+	}
+}
+
+func TestRTUOverUDPRetransmission(t *testing.T) {
+	var packager rtuPackager
+	addr, requests := udpResponder(t, func(n int, request []byte) [][]byte {
+		if n == 1 {
+			// lost datagram
+			return nil
+		}
+		packager.SlaveID = 2
+		stray, _ := packager.Encode(&ProtocolDataUnit{FunctionCode: FuncCodeReadHoldingRegisters, Data: []byte{2, 0, 1}})
+		packager.SlaveID = request[0]
+		other, _ := packager.Encode(&ProtocolDataUnit{FunctionCode: FuncCodeReadCoils, Data: []byte{1, 1}})
+		response, _ := packager.Encode(&ProtocolDataUnit{FunctionCode: FuncCodeReadHoldingRegisters, Data: []byte{2, 0xCA, 0xFE}})
+		return [][]byte{stray, other, response}
+	})
+
+	dials := 0
+	handler := NewRTUOverUDPClientHandler(addr, WithUDPDialer(func(ctx context.Context, network, address string) (net.Conn, error) {
+		dials++
+		return (&net.Dialer{}).DialContext(ctx, network, address)
+	}))
+	handler.SlaveID = 1
+	handler.Timeout = 100 * time.Millisecond
+	stats := NewStats()
+	handler.Observer = stats
+	defer handler.Close()
+
+	results, err := NewClient(handler).ReadHoldingRegisters(context.Background(), 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(results, []byte{0xCA, 0xFE}) {
+		t.Fatalf("unexpected results % x", results)
+	}
+	if got := requests(); got != 2 {
+		t.Fatalf("expected the request to be retransmitted once, got %d requests", got)
+	}
+	if snapshot := stats.Snapshot(); snapshot.Timeouts != 1 {
+		t.Fatalf("expected 1 timeout, got %d", snapshot.Timeouts)
+	}
+	if dials != 1 {
+		t.Fatalf("expected custom dialer to be used once, got %d", dials)
+	}
+}
+
+func TestRTUOverUDPTimeout(t *testing.T) {
+	addr, requests := udpResponder(t, func(int, []byte) [][]byte { return nil })
+
+	handler := NewRTUOverUDPClientHandler(addr)
+	handler.SlaveID = 1
+	handler.Timeout = 20 * time.Millisecond
+	handler.Retries = 1
+	defer handler.Close()
+
+	_, err := NewClient(handler).ReadCoils(context.Background(), 0, 1)
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+	if got := requests(); got != 2 {
+		t.Fatalf("expected 2 transmissions, got %d", got)
+	}
+}
+
+func TestRTUOverUDPContextDeadline(t *testing.T) {
+	addr, _ := udpResponder(t, func(int, []byte) [][]byte { return nil })
+
+	handler := NewRTUOverUDPClientHandler(addr)
+	handler.SlaveID = 1
+	handler.Timeout = time.Minute
+	defer handler.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := NewClient(handler).ReadCoils(ctx, 0, 1); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected the context deadline to end the request, took %v", elapsed)
+	}
+}
+
+func TestTCPOverUDPTransactionID(t *testing.T) {
+	addr, _ := udpResponder(t, func(_ int, request []byte) [][]byte {
+		stale := append([]byte(nil), request[:tcpHeaderSize]...)
+		stale[1]--
+		stale[5] = 3
+		stale = append(stale, FuncCodeReadHoldingRegisters|0x80, ExceptionCodeServerDeviceBusy)
+		response := append([]byte(nil), request[:tcpHeaderSize]...)
+		response[5] = 5
+		response = append(response, FuncCodeReadHoldingRegisters, 2, 0, 42)
+		return [][]byte{stale, response}
+	})
+
+	handler := NewTCPOverUDPClientHandler(addr)
+	handler.SlaveID = 1
+	handler.Timeout = time.Second
+	defer handler.Close()
+	client := NewClient(handler)
+
+	for i := 0; i < 2; i++ {
+		results, err := client.ReadHoldingRegisters(context.Background(), 0, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(results, []byte{0, 42}) {
+			t.Fatalf("unexpected results % x", results)
+		}
+	}
+}
+
+func TestASCIIResponseMatches(t *testing.T) {
+	request := []byte(":010300000001FB\r\n")
+	tests := []struct {
+		response string
+		matches  bool
+	}{
+		{":01030200007A\r\n", true},
+		{":018302FA\r\n", true},
+		{":02030200007A\r\n", false},
+		{":01040200007A\r\n", false},
+		{"01030200007A\r\n", false},
+		{":0", false},
+	}
+	for _, tt := range tests {
+		if got := asciiResponseMatches(request, []byte(tt.response)); got != tt.matches {
+			t.Errorf("%q: expected %v, got %v", tt.response, tt.matches, got)
+		}
+	}
+}
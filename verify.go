@@ -0,0 +1,153 @@
+package modbus
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// VerifyMismatch is a single value that differs on read back.
+type VerifyMismatch struct {
+	Address uint16
+	// Written and Read are register values, or 0 and 1 for coils.
+	Written, Read uint16
+}
+
+// VerifyError is returned by a VerifiedWriter if the device does not report
+// the written values on read back.
+type VerifyError struct {
+	// FunctionCode is the function code of the write.
+	FunctionCode byte
+	Mismatches   []VerifyMismatch
+}
+
+// Error implements the error interface
+func (e *VerifyError) Error() string {
+	addresses := make([]string, len(e.Mismatches))
+	for i, m := range e.Mismatches {
+		addresses[i] = fmt.Sprintf("%v (wrote '%v', read '%v')", m.Address, m.Written, m.Read)
+	}
+	return fmt.Sprintf("modbus: read back after function '%v' differs at address %s", e.FunctionCode, strings.Join(addresses, ", "))
+}
+
+// VerifiedWriter writes coils and holding registers with a Client and reads
+// the written range back to confirm that the device applied the values.
+type VerifiedWriter struct {
+	Client Client
+	// Delay is the time to wait after the write and between read backs, for
+	// devices that apply written values asynchronously.
+	Delay time.Duration
+	// Retries is the number of additional read backs if the values differ.
+	Retries int
+}
+
+// NewVerifiedWriter allocates a VerifiedWriter writing with client.
+func NewVerifiedWriter(client Client) *VerifiedWriter {
+	return &VerifiedWriter{Client: client}
+}
+
+// WriteSingleRegister writes a single holding register and reads it back.
+func (w *VerifiedWriter) WriteSingleRegister(ctx context.Context, address, value uint16) error {
+	if _, err := w.Client.WriteSingleRegister(ctx, address, value); err != nil {
+		return err
+	}
+	return w.verifyRegisters(ctx, FuncCodeWriteSingleRegister, address, dataBlock(value))
+}
+
+// WriteMultipleRegisters writes a block of holding registers and reads it back.
+func (w *VerifiedWriter) WriteMultipleRegisters(ctx context.Context, address, quantity uint16, value []byte) error {
+	if _, err := w.Client.WriteMultipleRegisters(ctx, address, quantity, value); err != nil {
+		return err
+	}
+	return w.verifyRegisters(ctx, FuncCodeWriteMultipleRegisters, address, value)
+}
+
+// WriteSingleCoil writes a single coil, 0xFF00 for ON and 0x0000 for OFF,
+// and reads it back.
+func (w *VerifiedWriter) WriteSingleCoil(ctx context.Context, address, value uint16) error {
+	if _, err := w.Client.WriteSingleCoil(ctx, address, value); err != nil {
+		return err
+	}
+	var bits byte
+	if value == 0xFF00 {
+		bits = 1
+	}
+	return w.verifyCoils(ctx, FuncCodeWriteSingleCoil, address, 1, []byte{bits})
+}
+
+// WriteMultipleCoils writes a sequence of coils and reads it back.
+func (w *VerifiedWriter) WriteMultipleCoils(ctx context.Context, address, quantity uint16, value []byte) error {
+	if _, err := w.Client.WriteMultipleCoils(ctx, address, quantity, value); err != nil {
+		return err
+	}
+	return w.verifyCoils(ctx, FuncCodeWriteMultipleCoils, address, quantity, value)
+}
+
+func (w *VerifiedWriter) verifyRegisters(ctx context.Context, functionCode byte, address uint16, written []byte) error {
+	quantity := uint16(len(written) / 2)
+	return w.verify(ctx, functionCode, func() ([]VerifyMismatch, error) {
+		results, err := w.Client.ReadHoldingRegisters(ctx, address, quantity)
+		if err != nil {
+			return nil, err
+		}
+		if len(results) != len(written) {
+			return nil, &DataSizeError{ExpectedBytes: len(written), ActualBytes: len(results)}
+		}
+		var mismatches []VerifyMismatch
+		for i := 0; i < len(written); i += 2 {
+			wrote, read := binary.BigEndian.Uint16(written[i:]), binary.BigEndian.Uint16(results[i:])
+			if wrote != read {
+				mismatches = append(mismatches, VerifyMismatch{Address: address + uint16(i/2), Written: wrote, Read: read})
+			}
+		}
+		return mismatches, nil
+	})
+}
+
+func (w *VerifiedWriter) verifyCoils(ctx context.Context, functionCode byte, address, quantity uint16, written []byte) error {
+	return w.verify(ctx, functionCode, func() ([]VerifyMismatch, error) {
+		results, err := w.Client.ReadCoils(ctx, address, quantity)
+		if err != nil {
+			return nil, err
+		}
+		if count := (int(quantity) + 7) / 8; len(results) < count || len(written) < count {
+			return nil, &DataSizeError{ExpectedBytes: count, ActualBytes: len(results)}
+		}
+		var mismatches []VerifyMismatch
+		for i := 0; i < int(quantity); i++ {
+			wrote, read := uint16(written[i/8]>>(i%8)&1), uint16(results[i/8]>>(i%8)&1)
+			if wrote != read {
+				mismatches = append(mismatches, VerifyMismatch{Address: address + uint16(i), Written: wrote, Read: read})
+			}
+		}
+		return mismatches, nil
+	})
+}
+
+// verify reads back with compare after Delay until there are no mismatches
+// or the retries are exhausted.
+func (w *VerifiedWriter) verify(ctx context.Context, functionCode byte, compare func() ([]VerifyMismatch, error)) error {
+	for attempt := 0; ; attempt++ {
+		if w.Delay > 0 {
+			timer := time.NewTimer(w.Delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+		mismatches, err := compare()
+		if err != nil {
+			return err
+		}
+		if len(mismatches) == 0 {
+			return nil
+		}
+		if attempt >= w.Retries {
+			return &VerifyError{FunctionCode: functionCode, Mismatches: mismatches}
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package modbus
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// memoryResponder answers coil and holding register requests from memory.
+// Writes to frozen addresses are acknowledged but not applied.
+type memoryResponder struct {
+	registers map[uint16]uint16
+	coils     map[uint16]bool
+	frozen    map[uint16]bool
+}
+
+func newMemoryResponder(frozen ...uint16) *memoryResponder {
+	m := &memoryResponder{registers: map[uint16]uint16{}, coils: map[uint16]bool{}, frozen: map[uint16]bool{}}
+	for _, address := range frozen {
+		m.frozen[address] = true
+	}
+	return m
+}
+
+func (m *memoryResponder) respond(request *ProtocolDataUnit) *ProtocolDataUnit {
+	data := request.Data
+	address, value := binary.BigEndian.Uint16(data), binary.BigEndian.Uint16(data[2:])
+	response := &ProtocolDataUnit{FunctionCode: request.FunctionCode, Data: data[:4]}
+	switch request.FunctionCode {
+	case FuncCodeReadHoldingRegisters:
+		response.Data = []byte{byte(2 * value)}
+		for i := uint16(0); i < value; i++ {
+			response.Data = binary.BigEndian.AppendUint16(response.Data, m.registers[address+i])
+		}
+	case FuncCodeReadCoils:
+		response.Data = make([]byte, 1+(value+7)/8)
+		response.Data[0] = byte(len(response.Data) - 1)
+		for i := uint16(0); i < value; i++ {
+			if m.coils[address+i] {
+				response.Data[1+i/8] |= 1 << (i % 8)
+			}
+		}
+	case FuncCodeWriteSingleRegister:
+		if !m.frozen[address] {
+			m.registers[address] = value
+		}
+	case FuncCodeWriteMultipleRegisters:
+		for i := uint16(0); i < value; i++ {
+			if !m.frozen[address+i] {
+				m.registers[address+i] = binary.BigEndian.Uint16(data[5+2*i:])
+			}
+		}
+	case FuncCodeMaskWriteRegister:
+		andMask, orMask := value, binary.BigEndian.Uint16(data[4:])
+		if !m.frozen[address] {
+			m.registers[address] = m.registers[address]&andMask | orMask&^andMask
+		}
+		response.Data = data
+	case FuncCodeWriteSingleCoil:
+		if !m.frozen[address] {
+			m.coils[address] = value == 0xFF00
+		}
+	case FuncCodeWriteMultipleCoils:
+		for i := uint16(0); i < value; i++ {
+			if !m.frozen[address+i] {
+				m.coils[address+i] = data[5+i/8]>>(i%8)&1 == 1
+			}
+		}
+	}
+	return response
+}
+
+func TestVerifiedWriter(t *testing.T) {
+	memory := newMemoryResponder()
+	tr := &pduTransporter{respond: memory.respond}
+	writer := NewVerifiedWriter(NewClient2(&rtuPackager{SlaveID: 1}, tr))
+	ctx := context.Background()
+
+	if err := writer.WriteSingleRegister(ctx, 1, 0x1234); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.WriteMultipleRegisters(ctx, 10, 2, []byte{0xCA, 0xFE, 0xBA, 0xBE}); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.WriteSingleCoil(ctx, 3, 0xFF00); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.WriteMultipleCoils(ctx, 20, 10, []byte{0x05, 0x02}); err != nil {
+		t.Fatal(err)
+	}
+	if len(tr.requests) != 8 {
+		t.Fatalf("expected a read back after every write, got %d requests", len(tr.requests))
+	}
+}
+
+func TestVerifiedWriterMismatch(t *testing.T) {
+	memory := newMemoryResponder(11, 22)
+	tr := &pduTransporter{respond: memory.respond}
+	writer := NewVerifiedWriter(NewClient2(&rtuPackager{SlaveID: 1}, tr))
+	writer.Retries = 2
+	ctx := context.Background()
+
+	err := writer.WriteMultipleRegisters(ctx, 10, 2, []byte{0xCA, 0xFE, 0xBA, 0xBE})
+	var verifyErr *VerifyError
+	if !errors.As(err, &verifyErr) {
+		t.Fatalf("expected *VerifyError, got %v", err)
+	}
+	want := []VerifyMismatch{{Address: 11, Written: 0xBABE, Read: 0}}
+	if verifyErr.FunctionCode != FuncCodeWriteMultipleRegisters || !reflect.DeepEqual(verifyErr.Mismatches, want) {
+		t.Fatalf("expected mismatches %+v, got %+v", want, verifyErr)
+	}
+	// the write and three read backs
+	if len(tr.requests) != 4 {
+		t.Fatalf("expected 4 requests, got %d", len(tr.requests))
+	}
+
+	err = writer.WriteMultipleCoils(ctx, 20, 10, []byte{0x05, 0x02})
+	if !errors.As(err, &verifyErr) {
+		t.Fatalf("expected *VerifyError, got %v", err)
+	}
+	want = []VerifyMismatch{{Address: 22, Written: 1, Read: 0}}
+	if !reflect.DeepEqual(verifyErr.Mismatches, want) {
+		t.Fatalf("expected mismatches %+v, got %+v", want, verifyErr.Mismatches)
+	}
+}